@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -11,14 +12,19 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/suborbital/systemspec/modulecache"
 	"github.com/suborbital/systemspec/tenant"
 )
 
 // Bundle represents a Module bundle.
 type Bundle struct {
 	filepath     string
+	data         []byte
 	TenantConfig *tenant.Config
 	staticFiles  map[string]bool
+
+	// Migration describes how the tenant config was upgraded if the bundle was written with an older spec version.
+	Migration *tenant.MigrationReport
 }
 
 // StaticFile returns a static file from the bundle, if it exists.
@@ -30,12 +36,12 @@ func (b *Bundle) StaticFile(filePathIn string) ([]byte, error) {
 		return nil, os.ErrNotExist
 	}
 
-	r, err := zip.OpenReader(b.filepath)
+	r, closer, err := b.zipReader()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open bundle")
 	}
 
-	defer r.Close()
+	defer closer.Close()
 
 	// re-add the static/ prefix to ensure sandboxing to the static directory.
 	staticFilePath := ensurePrefix(filePath, "static/")
@@ -68,6 +74,25 @@ func (b *Bundle) StaticFile(filePathIn string) ([]byte, error) {
 	return contents, nil
 }
 
+// zipReader opens the bundle's archive, from memory if the bundle was read with ReadBytes.
+func (b *Bundle) zipReader() (*zip.Reader, io.Closer, error) {
+	if b.data == nil {
+		rc, err := zip.OpenReader(b.filepath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return &rc.Reader, rc, nil
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b.data), int64(len(b.data)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, io.NopCloser(nil), nil
+}
+
 // Write writes a module bundle
 // based loosely on https://golang.org/src/archive/zip/example_test.go
 // staticFiles should be a map of *relative* filepaths to their associated files, with or without the `static/` prefix.
@@ -154,9 +179,24 @@ func writeFile(w *zip.Writer, name string, contents []byte) error {
 	return nil
 }
 
+// ReadOption configures how a bundle is read.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	cache *modulecache.Cache
+}
+
+// WithModuleCache causes Read to use module bytes from [cache] for any module whose FQMN it contains, as long as
+// they match the CRC-32 recorded in the bundle, and to store the bytes of the others in it.
+func WithModuleCache(cache *modulecache.Cache) ReadOption {
+	return func(o *readOptions) {
+		o.cache = cache
+	}
+}
+
 // Read reads a .wasm.zip file and returns the bundle of wasm modules
 // (suitable to be loaded into a wasmer instance).
-func Read(path string) (*Bundle, error) {
+func Read(path string, opts ...ReadOption) (*Bundle, error) {
 	// Open a zip archive for reading.
 	r, err := zip.OpenReader(path)
 	if err != nil {
@@ -170,22 +210,57 @@ func Read(path string) (*Bundle, error) {
 		staticFiles: map[string]bool{},
 	}
 
+	if err := readBundle(&r.Reader, bundle, opts); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// ReadBytes is Read for a bundle that is already in memory. The bundle keeps [data] to serve its
+// static files, so the caller must not modify it afterwards.
+func ReadBytes(data []byte, opts ...ReadOption) (*Bundle, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open bundle")
+	}
+
+	bundle := &Bundle{
+		data:        data,
+		staticFiles: map[string]bool{},
+	}
+
+	if err := readBundle(r, bundle, opts); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// readBundle reads the tenant config, modules, and static file list from [r] into [bundle].
+func readBundle(r *zip.Reader, bundle *Bundle, opts []ReadOption) error {
+	options := &readOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	// first, find the tenant config.
 	for _, f := range r.File {
 		if f.Name == "tenant.json" {
-			tenantConfig, err := readTenantConfig(f)
+			tenantConfig, report, err := readTenantConfig(f)
 			if err != nil {
-				return nil, errors.Wrap(err, "failed to readTenantConfig from bundle")
+				return errors.Wrap(err, "failed to readTenantConfig from bundle")
 			}
 
 			bundle.TenantConfig = tenantConfig
+			bundle.Migration = report
 
 			continue
 		}
 	}
 
 	if bundle.TenantConfig == nil {
-		return nil, errors.New("bundle is missing tenant.json")
+		return errors.New("bundle is missing tenant.json")
 	}
 
 	// Iterate through the files in the archive.
@@ -202,56 +277,79 @@ func Read(path string) (*Bundle, error) {
 			continue
 		}
 
-		rc, err := f.Open()
+		// for now, the bundle spec only supports the default namespace
+		FQMN := fmt.Sprintf("/name/default/%s", strings.TrimSuffix(f.Name, ".wasm"))
+
+		module, err := bundle.TenantConfig.FindModule(FQMN)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to open %s from bundle", f.Name)
+			return errors.Wrapf(err, "failed to FindModule for %s (%s)", f.Name, FQMN)
+		} else if module == nil {
+			return fmt.Errorf("unable to find Module for Wasm file %s (%s)", f.Name, FQMN)
 		}
 
-		wasmBytes, err := io.ReadAll(rc)
+		wasmBytes, err := readModule(f, module.FQMN, options.cache)
 		if err != nil {
-			_ = rc.Close()
-			return nil, errors.Wrapf(err, "failed to read %s from bundle", f.Name)
+			return err
 		}
 
-		_ = rc.Close()
+		module.WasmRef = tenant.NewWasmModuleRef(f.Name, module.FQMN, wasmBytes)
+	}
 
-		// for now, the bundle spec only supports the default namespace
-		FQMN := fmt.Sprintf("/name/default/%s", strings.TrimSuffix(f.Name, ".wasm"))
+	return nil
+}
 
-		module, err := bundle.TenantConfig.FindModule(FQMN)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to FindModule for %s (%s)", f.Name, FQMN)
-		} else if module == nil {
-			return nil, fmt.Errorf("unable to find Module for Wasm file %s (%s)", f.Name, FQMN)
+// readModule reads the Wasm bytes of a module from the bundle, or from the cache if it has the module's FQMN.
+// Cached bytes are only used if they match the checksum in the bundle, as a bundle can be rebuilt with
+// different bytes under the same FQMN.
+func readModule(f *zip.File, FQMN string, cache *modulecache.Cache) ([]byte, error) {
+	useCache := cache != nil && FQMN != ""
+
+	if useCache {
+		if wasmBytes, err := cache.Get(FQMN); err == nil && crc32.ChecksumIEEE(wasmBytes) == f.CRC32 {
+			return wasmBytes, nil
 		}
+	}
 
-		module.WasmRef = tenant.NewWasmModuleRef(f.Name, module.FQMN, wasmBytes)
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s from bundle", f.Name)
 	}
 
-	if bundle.TenantConfig == nil {
-		return nil, errors.New("bundle did not contain tenantConfig")
+	defer rc.Close()
+
+	wasmBytes, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s from bundle", f.Name)
 	}
 
-	return bundle, nil
+	if useCache {
+		// the bundle remains readable if the cache can't be written to.
+		_ = cache.Put(FQMN, wasmBytes)
+	}
+
+	return wasmBytes, nil
 }
 
-func readTenantConfig(f *zip.File) (*tenant.Config, error) {
+func readTenantConfig(f *zip.File) (*tenant.Config, *tenant.MigrationReport, error) {
 	file, err := f.Open()
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to open %s from bundle", f.Name)
+		return nil, nil, errors.Wrapf(err, "failed to open %s from bundle", f.Name)
 	}
 
 	tenantConfigBytes, err := io.ReadAll(file)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read %s from bundle", f.Name)
+		return nil, nil, errors.Wrapf(err, "failed to read %s from bundle", f.Name)
 	}
 
+	// bundles written with older spec versions are upgraded as they are read.
 	d := &tenant.Config{}
-	if err := d.Unmarshal(tenantConfigBytes); err != nil {
-		return nil, errors.Wrap(err, "failed to Unmarshal tenant config")
+
+	report, err := d.UnmarshalMigrate(tenantConfigBytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to UnmarshalMigrate tenant config")
 	}
 
-	return d, nil
+	return d, report, nil
 }
 
 func ensurePrefix(val, prefix string) string {
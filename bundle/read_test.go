@@ -0,0 +1,51 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func TestReadV1Bundle(t *testing.T) {
+	confBytes, err := os.ReadFile(filepath.Join("testdata", "v1", "tenant.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wasmFile, err := os.Open(filepath.Join("testdata", "v1", "hello.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer wasmFile.Close()
+
+	path := filepath.Join(t.TempDir(), "runnables.wasm.zip")
+
+	if err := Write(confBytes, []os.File{*wasmFile}, nil, path); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.Migration == nil || !bundle.Migration.Migrated() || bundle.Migration.From != 1 || len(bundle.Migration.Applied) != 1 {
+		t.Errorf("expected the bundle's migration to be reported, got %+v", bundle.Migration)
+	}
+
+	conf := bundle.TenantConfig
+	if conf.SpecVersion != tenant.CurrentSpecVersion || conf.DefaultNamespace.Workflows[0].Steps[0].FQMN != "/name/default/hello" {
+		t.Errorf("expected the tenant config to be migrated, got %+v", conf.DefaultNamespace.Workflows[0].Steps)
+	}
+
+	if conf.Modules[0].WasmRef == nil {
+		t.Error("expected the module to be read from the bundle")
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Error("expected the migrated config to be valid, got:", err)
+	}
+}
@@ -0,0 +1,300 @@
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// //////////////////////////////////////////////////////////////////////////////////
+// A cron expression describes the times at which something should run.
+//
+// Both the standard 5 field form and a 6 field form with leading seconds are supported:
+//
+//      <minute> <hour> <day of month> <month> <day of week>
+//      <second> <minute> <hour> <day of month> <month> <day of week>
+//      e.g. 0 9 * * MON-FRI (9am on weekdays)
+//
+// Each field can be *, a value, a range (1-5), a step (*/15 or 10-50/10), or a
+// comma-separated list of those. Months and days of the week can be given by name
+// (JAN, MON), and both 0 and 7 mean Sunday. As with Vixie cron, if both the day of
+// month and day of week are restricted, a day that matches either of them matches. A field
+// starting with * (such as */2) isn't restricted, so a day must match both fields.
+//
+// The descriptors @yearly (@annually), @monthly, @weekly, @daily (@midnight), and
+// @hourly are also accepted.
+//
+// Expressions are evaluated against the wall clock of a time zone. As with Vixie cron,
+// a time that the clocks skip when they go forward runs when the clocks jump, and
+// the times that the clocks repeat when they go back run on each occurrence.
+//
+// //////////////////////////////////////////////////////////////////////////////////
+
+var ErrCronParseFailure = errors.New("cron expression failed to parse")
+
+// Expression is a parsed cron expression.
+type Expression struct {
+	second, minute, hour, dom, month, dow uint64
+
+	// anyDay is true unless both the day of month and day of week fields are restricted, that is, neither starts with * or ?.
+	anyDay bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = field{name: "second", min: 0, max: 59}
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a 5 or 6 field cron expression, or a descriptor such as @daily.
+func Parse(expr string) (*Expression, error) {
+	spec := strings.TrimSpace(expr)
+
+	if strings.HasPrefix(spec, "@") {
+		replacement, exists := descriptors[strings.ToLower(spec)]
+		if !exists {
+			return nil, errors.Wrapf(ErrCronParseFailure, "unknown descriptor %q", spec)
+		}
+
+		spec = replacement
+	}
+
+	fields := strings.Fields(spec)
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, errors.Wrapf(ErrCronParseFailure, "%q has %d fields, expected 5 or 6", expr, len(fields))
+	}
+
+	e := &Expression{}
+
+	for i, f := range []struct {
+		field field
+		bits  *uint64
+	}{
+		{secondField, &e.second},
+		{minuteField, &e.minute},
+		{hourField, &e.hour},
+		{domField, &e.dom},
+		{monthField, &e.month},
+		{dowField, &e.dow},
+	} {
+		set, err := f.field.parse(fields[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %q", expr)
+		}
+
+		*f.bits = set
+	}
+
+	// 7 is an alias for Sunday.
+	if e.dow&(1<<7) != 0 {
+		e.dow = e.dow&^(1<<7) | 1
+	}
+
+	e.anyDay = isWildcard(fields[3]) || isWildcard(fields[5])
+
+	return e, nil
+}
+
+// Next returns the first time matching the expression that is after t, in t's location. If the matching time
+// is skipped because the clocks go forward, the time that the clocks jump to is returned instead. The zero time
+// is returned if there is no such time within the next five years, such as for February 30th.
+func (e *Expression) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// start from the next whole second.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		var next time.Time
+
+		switch {
+		case e.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !e.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case e.hour&(1<<uint(t.Hour())) == 0:
+			// move forward by the time left in the hour on the wall clock rather than with time.Date, which
+			// picks the later occurrence of an hour that is repeated when the clocks go back.
+			next = t.Add(time.Duration(60-t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		case e.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Duration(60-t.Second()) * time.Second)
+		case e.second&(1<<uint(t.Second())) == 0:
+			next = t.Add(time.Second)
+		default:
+			return t
+		}
+
+		if e.skipped(t, next) {
+			return next
+		}
+
+		t = next
+	}
+
+	return time.Time{}
+}
+
+// skipped returns true if the clocks go forward between t and next, skipping a time that matches the expression.
+func (e *Expression) skipped(t, next time.Time) bool {
+	// compare wall clock times as though they were in UTC, which has no transitions.
+	gapStart := wallClock(t).Add(next.Sub(t))
+	gapEnd := wallClock(next)
+
+	for wall := gapStart; wall.Before(gapEnd); wall = wall.Add(time.Second) {
+		if e.matches(wall) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns true if every field of the expression matches t.
+func (e *Expression) matches(t time.Time) bool {
+	return e.month&(1<<uint(t.Month())) != 0 &&
+		e.dayMatches(t) &&
+		e.hour&(1<<uint(t.Hour())) != 0 &&
+		e.minute&(1<<uint(t.Minute())) != 0 &&
+		e.second&(1<<uint(t.Second())) != 0
+}
+
+// wallClock returns the time shown on the wall clock at t, as a UTC time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// dayMatches returns true if t's day matches the day of month and day of week fields.
+func (e *Expression) dayMatches(t time.Time) bool {
+	dom := e.dom&(1<<uint(t.Day())) != 0
+	dow := e.dow&(1<<uint(t.Weekday())) != 0
+
+	if e.anyDay {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// parse returns the set of values that a field matches as a bitset.
+func (f field) parse(spec string) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, hasStep := strings.Cut(part, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepSpec)
+			if err != nil || step < 1 {
+				return 0, errors.Wrapf(ErrCronParseFailure, "invalid step %q in %s field", stepSpec, f.name)
+			}
+		}
+
+		var low, high int
+
+		switch {
+		case rangeSpec == "*" || rangeSpec == "?":
+			low, high = f.min, f.max
+
+			// 7 is only an alias for Sunday, so * shouldn't include it twice.
+			if f.name == dowField.name {
+				high = 6
+			}
+		case strings.Contains(rangeSpec, "-"):
+			lowSpec, highSpec, _ := strings.Cut(rangeSpec, "-")
+
+			var err error
+
+			if low, err = f.value(lowSpec); err != nil {
+				return 0, err
+			}
+
+			if high, err = f.value(highSpec); err != nil {
+				return 0, err
+			}
+
+			if low > high {
+				return 0, errors.Wrapf(ErrCronParseFailure, "range %q in %s field is backwards", rangeSpec, f.name)
+			}
+		default:
+			var err error
+
+			if low, err = f.value(rangeSpec); err != nil {
+				return 0, err
+			}
+
+			high = low
+
+			// a step from a single value, such as 5/15, runs to the end of the field's range.
+			if hasStep {
+				high = f.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+// value parses a single number or name, checking it is within the field's range.
+func (f field) value(spec string) (int, error) {
+	if v, exists := f.names[strings.ToLower(spec)]; exists {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(spec)
+	if err != nil {
+		return 0, errors.Wrapf(ErrCronParseFailure, "invalid value %q in %s field", spec, f.name)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, errors.Wrapf(ErrCronParseFailure, "%s value %d is outside of %d-%d", f.name, v, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// isWildcard returns true if a day field starts with * or ?, as Vixie cron only treats the day of month and day
+// of week as restricted when they don't, so that */2 alongside a day of week must match both.
+func isWildcard(spec string) bool {
+	return strings.HasPrefix(spec, "*") || strings.HasPrefix(spec, "?")
+}
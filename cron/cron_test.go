@@ -0,0 +1,209 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+
+	// Friday 2023-03-24 10:00 in Berlin.
+	from := time.Date(2023, 3, 24, 10, 0, 0, 0, berlin)
+
+	tests := map[string]struct {
+		expr     string
+		from     time.Time
+		expected []time.Time
+	}{
+		"weekdays at 9am": {
+			expr: "0 9 * * MON-FRI",
+			from: from,
+			expected: []time.Time{
+				time.Date(2023, 3, 27, 9, 0, 0, 0, berlin),
+				time.Date(2023, 3, 28, 9, 0, 0, 0, berlin),
+			},
+		},
+		"every 15 minutes": {
+			expr: "*/15 * * * *",
+			from: from.Add(time.Minute),
+			expected: []time.Time{
+				time.Date(2023, 3, 24, 10, 15, 0, 0, berlin),
+				time.Date(2023, 3, 24, 10, 30, 0, 0, berlin),
+			},
+		},
+		"seconds": {
+			expr: "30 0 10 * * *",
+			from: from,
+			expected: []time.Time{
+				time.Date(2023, 3, 24, 10, 0, 30, 0, berlin),
+				time.Date(2023, 3, 25, 10, 0, 30, 0, berlin),
+			},
+		},
+		"day of month or day of week": {
+			expr: "0 0 1 * SUN",
+			from: from,
+			expected: []time.Time{
+				time.Date(2023, 3, 26, 0, 0, 0, 0, berlin),
+				time.Date(2023, 4, 1, 0, 0, 0, 0, berlin),
+			},
+		},
+		// a day of month starting with * isn't a restriction, so only odd days that are Mondays match.
+		"stepped day of month and day of week": {
+			expr: "0 0 */2 * MON",
+			from: time.Date(2023, 4, 4, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2023, 4, 17, 0, 0, 0, 0, berlin),
+				time.Date(2023, 5, 1, 0, 0, 0, 0, berlin),
+			},
+		},
+		"sunday as 7": {
+			expr: "0 12 * * 7",
+			from: from,
+			expected: []time.Time{
+				time.Date(2023, 3, 26, 12, 0, 0, 0, berlin),
+				time.Date(2023, 4, 2, 12, 0, 0, 0, berlin),
+			},
+		},
+		"monthly descriptor": {
+			expr: "@monthly",
+			from: from,
+			expected: []time.Time{
+				time.Date(2023, 4, 1, 0, 0, 0, 0, berlin),
+				time.Date(2023, 5, 1, 0, 0, 0, 0, berlin),
+			},
+		},
+		// Berlin skips from 2am to 3am on 2023-03-26, so that day's run happens when the clocks jump.
+		"skipped by daylight saving": {
+			expr: "30 2 * * *",
+			from: from,
+			expected: []time.Time{
+				time.Date(2023, 3, 25, 2, 30, 0, 0, berlin),
+				time.Date(2023, 3, 26, 1, 0, 0, 0, time.UTC),
+				time.Date(2023, 3, 27, 2, 30, 0, 0, berlin),
+			},
+		},
+		// Berlin repeats 2am to 3am on 2023-10-29, and hourly runs continue through both.
+		"repeated by daylight saving": {
+			expr: "0 * * * *",
+			from: time.Date(2023, 10, 29, 1, 30, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2023, 10, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 10, 29, 1, 0, 0, 0, time.UTC),
+				time.Date(2023, 10, 29, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		"leap day": {
+			expr: "0 0 29 FEB *",
+			from: from,
+			expected: []time.Time{
+				time.Date(2024, 2, 29, 0, 0, 0, 0, berlin),
+				time.Date(2028, 2, 29, 0, 0, 0, 0, berlin),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		expr, err := Parse(tc.expr)
+		if err != nil {
+			t.Errorf("%s: failed to Parse: %s", name, err)
+			continue
+		}
+
+		next := tc.from
+
+		for i, expected := range tc.expected {
+			next = expr.Next(next)
+			if !next.Equal(expected) {
+				t.Errorf("%s: expected run %d to be %s, got %s", name, i, expected, next)
+			}
+		}
+	}
+}
+
+func TestParseNextHalfHourOffset(t *testing.T) {
+	adelaide, err := time.LoadLocation("Australia/Adelaide")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+
+	tests := map[string]struct {
+		expr     string
+		from     time.Time
+		expected []time.Time
+	}{
+		// Adelaide repeats 2am to 3am on 2023-04-02, going from UTC+10:30 to UTC+9:30.
+		"repeated by daylight saving": {
+			expr: "0 2-4 * * *",
+			from: time.Date(2023, 4, 2, 1, 30, 0, 0, adelaide),
+			expected: []time.Time{
+				time.Date(2023, 4, 1, 15, 30, 0, 0, time.UTC),
+				time.Date(2023, 4, 1, 16, 30, 0, 0, time.UTC),
+				time.Date(2023, 4, 1, 17, 30, 0, 0, time.UTC),
+				time.Date(2023, 4, 1, 18, 30, 0, 0, time.UTC),
+			},
+		},
+		// Adelaide skips from 2am to 3am on 2023-10-01.
+		"skipped by daylight saving": {
+			expr: "30 2 * * *",
+			from: time.Date(2023, 9, 30, 12, 0, 0, 0, adelaide),
+			expected: []time.Time{
+				time.Date(2023, 9, 30, 16, 30, 0, 0, time.UTC),
+				time.Date(2023, 10, 2, 2, 30, 0, 0, adelaide),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		expr, err := Parse(tc.expr)
+		if err != nil {
+			t.Errorf("%s: failed to Parse: %s", name, err)
+			continue
+		}
+
+		next := tc.from
+
+		for i, expected := range tc.expected {
+			next = expr.Next(next)
+			if !next.Equal(expected) {
+				t.Errorf("%s: expected run %d to be %s, got %s", name, i, expected, next)
+			}
+		}
+	}
+}
+
+func TestParseNextNever(t *testing.T) {
+	expr, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next := expr.Next(time.Now()); !next.IsZero() {
+		t.Error("expected February 30th never to happen, got", next)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"@fortnightly",
+	} {
+		if _, err := Parse(expr); !errors.Is(err, ErrCronParseFailure) {
+			t.Errorf("expected %q to fail with ErrCronParseFailure, got %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package modulecache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotCached is returned when the cache does not contain a module for the requested ref.
+var ErrNotCached = errors.New("module is not cached")
+
+const (
+	blobsDir = "blobs"
+	refsDir  = "refs"
+)
+
+// Cache is an on-disk, content-addressed cache of module bytes keyed by a ref that identifies a module version,
+// such as its FQMN (which, unlike the FQMN's ref alone, is unique across tenants). Module bytes are stored once per
+// SHA-256 digest, and each ref points to a digest. The total size of stored modules is bounded by evicting the least
+// recently used, and the contents are verified against their digest each time they are read.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	size     int64
+
+	// refs maps each ref to the digest of its contents.
+	refs map[string]string
+
+	// blobs maps each digest to its element in lru, whose most recently used end is the front.
+	blobs map[string]*list.Element
+	lru   *list.List
+
+	lock sync.Mutex
+}
+
+// blob is a stored module.
+type blob struct {
+	digest string
+	size   int64
+}
+
+// New creates a Cache in [dir] holding at most [maxBytes] of module data, or unlimited if maxBytes is 0.
+// Modules already present in dir from a previous run are loaded, least recently used first.
+func New(dir string, maxBytes int64) (*Cache, error) {
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		refs:     map[string]string{},
+		blobs:    map[string]*list.Element{},
+		lru:      list.New(),
+		lock:     sync.Mutex{},
+	}
+
+	for _, sub := range []string{blobsDir, refsDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, errors.Wrap(err, "failed to MkdirAll")
+		}
+	}
+
+	if err := c.load(); err != nil {
+		return nil, errors.Wrap(err, "failed to load")
+	}
+
+	return c, nil
+}
+
+// Get returns the module bytes cached for the given ref, or ErrNotCached. If the stored bytes no longer match
+// their digest, they are removed and ErrNotCached is returned.
+func (c *Cache) Get(ref string) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	digest, exists := c.refs[ref]
+	if !exists {
+		return nil, ErrNotCached
+	}
+
+	elem, exists := c.blobs[digest]
+	if !exists {
+		delete(c.refs, ref)
+		return nil, ErrNotCached
+	}
+
+	data, err := os.ReadFile(c.blobPath(digest))
+	if err != nil || Digest(data) != digest {
+		c.evict(elem)
+		return nil, ErrNotCached
+	}
+
+	c.lru.MoveToFront(elem)
+
+	// persist the recency so that it survives restarts, but a failure to do so doesn't affect the read.
+	now := time.Now()
+	_ = os.Chtimes(c.blobPath(digest), now, now)
+
+	return data, nil
+}
+
+// Put stores the module bytes for the given ref, evicting the least recently used modules if needed.
+// Modules larger than the cache's maximum size are not stored.
+func (c *Cache) Put(ref string, data []byte) error {
+	if ref == "" {
+		return errors.New("ref must not be empty")
+	}
+
+	size := int64(len(data))
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return nil
+	}
+
+	digest := Digest(data)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, exists := c.blobs[digest]; exists {
+		c.lru.MoveToFront(elem)
+	} else {
+		if err := writeAtomic(c.blobPath(digest), data); err != nil {
+			return errors.Wrap(err, "failed to write module")
+		}
+
+		c.blobs[digest] = c.lru.PushFront(&blob{digest: digest, size: size})
+		c.size += size
+	}
+
+	if c.refs[ref] != digest {
+		if err := writeAtomic(c.refPath(ref), []byte(ref+"\n"+digest)); err != nil {
+			return errors.Wrap(err, "failed to write ref")
+		}
+
+		c.refs[ref] = digest
+	}
+
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.evict(c.lru.Back())
+	}
+
+	return nil
+}
+
+// Size returns the total size of the cached modules.
+func (c *Cache) Size() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
+
+// Digest returns the hex-encoded SHA-256 digest of the given module bytes.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// evict removes a blob and every ref pointing to it. The lock must be held.
+func (c *Cache) evict(elem *list.Element) {
+	b := elem.Value.(*blob)
+
+	c.lru.Remove(elem)
+	delete(c.blobs, b.digest)
+	c.size -= b.size
+
+	_ = os.Remove(c.blobPath(b.digest))
+
+	for ref, digest := range c.refs {
+		if digest == b.digest {
+			delete(c.refs, ref)
+			_ = os.Remove(c.refPath(ref))
+		}
+	}
+}
+
+// load populates the cache from the contents of its directory.
+func (c *Cache) load() error {
+	entries, err := os.ReadDir(filepath.Join(c.dir, blobsDir))
+	if err != nil {
+		return errors.Wrap(err, "failed to ReadDir blobs")
+	}
+
+	type stored struct {
+		blob
+		modTime time.Time
+	}
+
+	blobs := []stored{}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		blobs = append(blobs, stored{blob: blob{digest: entry.Name(), size: info.Size()}, modTime: info.ModTime()})
+	}
+
+	// push the most recently used last so that it ends up at the front.
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].modTime.Before(blobs[j].modTime) })
+
+	for i := range blobs {
+		b := blobs[i].blob
+		c.blobs[b.digest] = c.lru.PushFront(&b)
+		c.size += b.size
+	}
+
+	refs, err := os.ReadDir(filepath.Join(c.dir, refsDir))
+	if err != nil {
+		return errors.Wrap(err, "failed to ReadDir refs")
+	}
+
+	for _, entry := range refs {
+		contents, err := os.ReadFile(filepath.Join(c.dir, refsDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		ref, digest, found := strings.Cut(string(contents), "\n")
+		if _, exists := c.blobs[digest]; !found || !exists {
+			_ = os.Remove(filepath.Join(c.dir, refsDir, entry.Name()))
+			continue
+		}
+
+		c.refs[ref] = digest
+	}
+
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.evict(c.lru.Back())
+	}
+
+	return nil
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, blobsDir, digest)
+}
+
+// refPath returns the path of the file recording a ref's digest. Refs are hashed to form a safe filename.
+func (c *Cache) refPath(ref string) string {
+	return filepath.Join(c.dir, refsDir, Digest([]byte(ref)))
+}
+
+// writeAtomic writes data to a temporary file and renames it into place so that readers never see a partial file.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to CreateTemp")
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to Write")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to Close")
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to Rename")
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package modulecache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := New(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{"a", "b"} {
+		if err := cache.Put(ref, bytes.Repeat([]byte(ref), 4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// use a so that b is the least recently used.
+	if _, err := cache.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Put("c", []byte("cccc")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.Get("b"); !errors.Is(err, ErrNotCached) {
+		t.Error("expected b to be evicted, got:", err)
+	}
+
+	for _, ref := range []string{"a", "c"} {
+		if _, err := cache.Get(ref); err != nil {
+			t.Errorf("expected %s to be cached, got: %s", ref, err)
+		}
+	}
+
+	if cache.Size() != 8 {
+		t.Errorf("expected size 8, got %d", cache.Size())
+	}
+
+	if err := cache.Put("huge", bytes.Repeat([]byte("h"), 11)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.Get("huge"); !errors.Is(err, ErrNotCached) {
+		t.Error("expected a module larger than the cache not to be stored, got:", err)
+	}
+}
+
+func TestCacheSharesContentAndPersists(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{"one", "two"} {
+		if err := cache.Put(ref, []byte("same bytes")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cache.Size() != int64(len("same bytes")) {
+		t.Errorf("expected identical modules to be stored once, got size %d", cache.Size())
+	}
+
+	reopened, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := reopened.Get("two")
+	if err != nil || string(data) != "same bytes" {
+		t.Errorf("expected the module to persist, got %q (%v)", data, err)
+	}
+}
+
+func TestCacheVerifiesContents(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Put("ref", []byte("module")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, blobsDir, Digest([]byte("module"))), []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.Get("ref"); !errors.Is(err, ErrNotCached) {
+		t.Error("expected a corrupted module to be treated as not cached, got:", err)
+	}
+
+	if cache.Size() != 0 {
+		t.Errorf("expected the corrupted module to be removed, got size %d", cache.Size())
+	}
+}
@@ -0,0 +1,27 @@
+// gen writes the checked-in JSON Schemas to the current directory. It is run by go generate in the schema package.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/suborbital/systemspec/schema"
+)
+
+func main() {
+	files := map[string]*schema.Schema{
+		schema.TenantSchemaFile:       schema.Tenant(),
+		schema.CapabilitiesSchemaFile: schema.Capabilities(),
+	}
+
+	for name, s := range files {
+		b, err := schema.Marshal(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := os.WriteFile(name, b, 0600); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package schema
+
+//go:generate go run ./gen
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// Draft is the JSON Schema dialect of the generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// TenantSchemaFile and CapabilitiesSchemaFile are the names of the checked-in schemas in this package's directory.
+const (
+	TenantSchemaFile       = "tenant.schema.json"
+	CapabilitiesSchemaFile = "capabilities.schema.json"
+)
+
+// Schema is a JSON Schema, containing only the keywords used by the generator. Type is either a
+// single type name or a list of them.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Const                any                `json:"const,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// enums lists the allowed values of string fields, keyed by "<Go type>.<json field>".
+var enums = map[string][]any{
+	"Connection.type":   {tenant.ConnectionTypeNATS, tenant.ConnectionTypeKafka},
+	"Trigger.source":    {tenant.InputSourceServer, tenant.InputSourceNATS, tenant.InputSourceKafka},
+	"ErrHandler.policy": {tenant.ErrPolicyReturn, tenant.ErrPolicyContinue, tenant.ErrPolicyFallback},
+	"Schedule.overlap":  {tenant.OverlapSkip, tenant.OverlapQueue},
+	"Condition.op":      {tenant.ConditionExists, tenant.ConditionNotExists, tenant.ConditionEquals, tenant.ConditionNotEquals},
+}
+
+// required lists the fields that Config.Validate requires, keyed by Go type.
+var required = map[string][]string{
+	"Config":     {"identifier", "modules"},
+	"Module":     {"name", "namespace"},
+	"Workflow":   {"name", "steps"},
+	"Connection": {"type"},
+	"AuthHeader": {"value"},
+	"Condition":  {"key", "op"},
+}
+
+// Tenant returns the JSON Schema for tenant.json files.
+func Tenant() *Schema {
+	s := generate(reflect.TypeOf(tenant.Config{}))
+	s.ID = "https://suborbital.dev/schemas/" + TenantSchemaFile
+	s.Title = "tenant.json"
+	s.Description = "the configuration of a tenant, its namespaces, and its modules"
+
+	return s
+}
+
+// Capabilities returns the JSON Schema for capability configs.
+func Capabilities() *Schema {
+	s := generate(reflect.TypeOf(capabilities.CapabilityConfig{}))
+	s.ID = "https://suborbital.dev/schemas/" + CapabilitiesSchemaFile
+	s.Title = "capabilities"
+	s.Description = "the capabilities available to a namespace's modules"
+
+	return s
+}
+
+// Marshal returns the indented JSON encoding of the schema, as it is checked in.
+func Marshal(s *Schema) ([]byte, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to json.MarshalIndent")
+	}
+
+	return append(b, '\n'), nil
+}
+
+// generate returns the root schema for the given struct type, with every named struct type it uses in $defs.
+func generate(root reflect.Type) *Schema {
+	g := &generator{defs: map[string]*Schema{}}
+
+	s := g.object(root)
+	s.Schema = Draft
+	s.Defs = g.defs
+
+	return s
+}
+
+type generator struct {
+	defs map[string]*Schema
+}
+
+// schemaFor returns the schema for a value of the given type, adding named struct types to defs and referencing them.
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Struct:
+		if _, exists := g.defs[t.Name()]; !exists {
+			// reserve the name first so that recursive types terminate.
+			g.defs[t.Name()] = &Schema{}
+			*g.defs[t.Name()] = *g.object(t)
+		}
+
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Description: "base64 encoded bytes"}
+		}
+
+		// nil slices and maps are encoded as null by encoding/json.
+		return &Schema{Type: []string{"array", "null"}, Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: []string{"object", "null"}, AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+
+	// interfaces and other dynamic values can hold anything.
+	return &Schema{}
+}
+
+// object returns the schema for the given struct type's exported JSON fields. Unknown properties are allowed,
+// as they are ignored by the Go decoder rather than rejected.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		switch {
+		case name == "-":
+			continue
+		case name == "" && field.Anonymous:
+			embedded := g.object(field.Type)
+			for prop, propSchema := range embedded.Properties {
+				s.Properties[prop] = propSchema
+			}
+
+			s.Required = append(s.Required, embedded.Required...)
+
+			continue
+		case name == "":
+			name = field.Name
+		}
+
+		prop := g.schemaFor(field.Type)
+		if values, exists := enums[t.Name()+"."+name]; exists {
+			prop.Enum = values
+		}
+
+		s.Properties[name] = prop
+	}
+
+	for _, name := range required[t.Name()] {
+		if _, exists := s.Properties[name]; exists {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	sort.Strings(s.Required)
+
+	return s
+}
@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestCheckedInSchemasAreCurrent(t *testing.T) {
+	for name, s := range map[string]*Schema{TenantSchemaFile: Tenant(), CapabilitiesSchemaFile: Capabilities()} {
+		generated, err := Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkedIn, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(generated, checkedIn) {
+			t.Errorf("%s is out of date, run go generate ./schema", name)
+		}
+	}
+}
+
+func TestTenantSchema(t *testing.T) {
+	s := Tenant()
+
+	connection := s.Defs["Connection"]
+	if connection == nil || len(connection.Properties["type"].Enum) != 2 {
+		t.Fatalf("expected the connection type to be an enum, got %+v", connection)
+	}
+
+	trigger := s.Defs["Trigger"]
+	if trigger == nil || len(trigger.Properties["source"].Enum) != 3 {
+		t.Fatalf("expected the trigger source to be an enum, got %+v", trigger)
+	}
+
+	if s.Defs["CapabilityConfig"] == nil || s.Defs["LoggerConfig"].Properties["Logger"] != nil {
+		t.Error("expected capabilities to be included without the logger instance")
+	}
+
+	if s.AdditionalProperties != nil || s.Defs["Workflow"].AdditionalProperties != nil {
+		t.Error("expected unknown properties to be allowed, as the Go decoder ignores them")
+	}
+
+	if s.Properties["namespaces"].Items.Ref != "#/$defs/NamespaceConfig" {
+		t.Errorf("unexpected namespaces schema %+v", s.Properties["namespaces"])
+	}
+
+	// the schema must itself be valid JSON that round trips.
+	b, err := Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !json.Valid(b) {
+		t.Error("schema is not valid JSON")
+	}
+}
@@ -1,30 +1,49 @@
 package bundle
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/suborbital/systemspec/bundle"
-	"github.com/suborbital/systemspec/capabilities"
 	"github.com/suborbital/systemspec/system"
 	"github.com/suborbital/systemspec/tenant"
 )
 
-// BundleSource is a Source backed by a bundle file.
+// watchInterval is how often the bundle file is checked for changes.
+const watchInterval = time.Second
+
+// BundleSource is a Source backed by a bundle file. Once started, the file is watched until Close is called,
+// and the bundle is reloaded (and the system version bumped) whenever its contents change.
+// Previous tenant versions remain available up to system.DefaultVersionHistory, see WithVersionHistory.
 type BundleSource struct {
-	path   string
-	bundle *bundle.Bundle
+	versionedSource
+
+	path    string
+	bundle  *bundle.Bundle
+	hash    []byte
+	version int64
+	lastErr error
+	events  *system.Broadcaster
+
+	// stop stops watching the bundle file, once started.
+	stop context.CancelFunc
 
 	lock sync.RWMutex
 }
 
 // NewBundleSource creates a new BundleSource that looks for a bundle at [path].
-func NewBundleSource(path string) system.Source {
+func NewBundleSource(path string, opts ...Option) system.Source {
 	b := &BundleSource{
-		path: path,
-		lock: sync.RWMutex{},
+		versionedSource: newVersionedSource(system.DefaultVersionHistory, opts),
+		path:            path,
+		events:          system.NewBroadcaster(),
+		lock:            sync.RWMutex{},
 	}
 
 	return b
@@ -32,17 +51,52 @@ func NewBundleSource(path string) system.Source {
 
 // Start initializes the system source.
 func (b *BundleSource) Start() error {
-	if err := b.findBundle(); err != nil {
+	return b.StartContext(context.Background())
+}
+
+// StartContext initializes the system source, waiting until a bundle is found or ctx is done.
+// The bundle file is then watched for changes until Close is called, regardless of ctx.
+func (b *BundleSource) StartContext(ctx context.Context) error {
+	if err := b.findBundle(ctx); err != nil {
 		return errors.Wrap(err, "failed to findBundle")
 	}
 
+	watchCtx, stop := context.WithCancel(context.Background())
+
+	b.lock.Lock()
+	if b.stop != nil {
+		// the source was already started, so only the newest watcher is kept.
+		b.stop()
+	}
+
+	b.stop = stop
+	b.lock.Unlock()
+
+	go b.watchBundle(watchCtx)
+
+	return nil
+}
+
+// Close stops watching the bundle file. The last loaded bundle continues to be served.
+func (b *BundleSource) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stop != nil {
+		b.stop()
+		b.stop = nil
+	}
+
 	return nil
 }
 
 // State returns the state of the entire system.
-func (_ *BundleSource) State() (*system.State, error) {
+func (b *BundleSource) State() (*system.State, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
 	s := &system.State{
-		SystemVersion: 1,
+		SystemVersion: b.version,
 	}
 
 	return s, nil
@@ -50,9 +104,16 @@ func (_ *BundleSource) State() (*system.State, error) {
 
 // Overview gets the overview for the entire system.
 func (b *BundleSource) Overview() (*system.Overview, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.bundle == nil {
+		return nil, system.ErrTenantNotFound
+	}
+
 	ovv := &system.Overview{
 		State: system.State{
-			SystemVersion: 1,
+			SystemVersion: b.version,
 		},
 		TenantRefs: system.References{
 			Identifiers: map[string]int64{
@@ -64,16 +125,17 @@ func (b *BundleSource) Overview() (*system.Overview, error) {
 	return ovv, nil
 }
 
-// Modules returns the Modules for the system.
-func (b *BundleSource) TenantOverview(ident string) (*system.TenantOverview, error) {
-	if !b.checkIdentifier(ident) {
-		return nil, system.ErrTenantNotFound
-	}
+// Watch returns a channel of events describing each change to the bundle.
+func (b *BundleSource) Watch(ctx context.Context) (<-chan system.Event, error) {
+	return b.events.Watch(ctx), nil
+}
 
+// TenantOverview gets the overview for the currently loaded version of the given tenant.
+func (b *BundleSource) TenantOverview(ident string) (*system.TenantOverview, error) {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
 
-	if b.bundle == nil {
+	if b.bundle == nil || b.bundle.TenantConfig.Identifier != ident {
 		return nil, system.ErrTenantNotFound
 	}
 
@@ -86,160 +148,127 @@ func (b *BundleSource) TenantOverview(ident string) (*system.TenantOverview, err
 	return ovv, nil
 }
 
-// GetModule searches for and returns the requested module
-// otherwise system.ErrModuleNotFound.
-func (b *BundleSource) GetModule(FQMN string) (*tenant.Module, error) {
+// LastError returns the error encountered by the most recent failed reload, or nil if the
+// currently loaded bundle is the latest one found on disk.
+func (b *BundleSource) LastError() error {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
 
-	if b.bundle == nil {
-		return nil, system.ErrModuleNotFound
-	}
-
-	for i, r := range b.bundle.TenantConfig.Modules {
-		if r.FQMN == FQMN {
-			return &b.bundle.TenantConfig.Modules[i], nil
-		}
-	}
-
-	return nil, system.ErrModuleNotFound
+	return b.lastErr
 }
 
-// Workflows returns the workflows for the system.
-func (b *BundleSource) Workflows(ident, namespace string, _ int64) ([]tenant.Workflow, error) {
-	if !b.checkIdentifier(ident) {
-		return nil, system.ErrTenantNotFound
-	}
-
-	b.lock.RLock()
-	defer b.lock.RUnlock()
-
-	if b.bundle == nil {
-		return nil, system.ErrTenantNotFound
-	}
+// findBundle loops until it finds a bundle at the configured path or ctx is done.
+func (b *BundleSource) findBundle(ctx context.Context) error {
+	for {
+		_, err := b.reloadBundle()
+		if err == nil {
+			break
+		}
 
-	if namespace == "default" {
-		return b.bundle.TenantConfig.DefaultNamespace.Workflows, nil
-	}
+		if errors.Is(err, errInvalidBundle) {
+			return err
+		}
 
-	for _, n := range b.bundle.TenantConfig.Namespaces {
-		if n.Name == namespace {
-			return n.Workflows, nil
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
 		}
 	}
 
-	return nil, system.ErrNamespaceNotFound
+	return nil
 }
 
-// Connections returns the Connections for the system.
-func (b *BundleSource) Connections(ident, namespace string, _ int64) ([]tenant.Connection, error) {
-	b.lock.RLock()
-	defer b.lock.RUnlock()
-
-	if b.bundle == nil {
-		return nil, system.ErrTenantNotFound
-	}
+// watchBundle loops until ctx is cancelled by Close, reloading the bundle whenever the file at the configured path changes.
+func (b *BundleSource) watchBundle(ctx context.Context) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
 
-	if !b.checkIdentifier(ident) {
-		return nil, system.ErrTenantNotFound
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// errors are recorded by reloadBundle and the last good bundle continues to be served.
+			_, _ = b.reloadBundle()
+		}
 	}
+}
 
-	if namespace == "default" {
-		return b.bundle.TenantConfig.DefaultNamespace.Connections, nil
-	}
+var errInvalidBundle = errors.New("bundle is invalid")
 
-	for _, n := range b.bundle.TenantConfig.Namespaces {
-		if n.Name == namespace {
-			return n.Connections, nil
-		}
+// reloadBundle reads and validates the bundle at the configured path and swaps it in if its
+// contents have changed, bumping the system version. It returns true if a new bundle was loaded.
+func (b *BundleSource) reloadBundle() (bool, error) {
+	// the file is read once so that the hash and the parsed bundle always describe the same contents,
+	// even if the file is being rewritten.
+	contents, err := os.ReadFile(b.path)
+	if err != nil {
+		return false, b.setLastErr(errors.Wrap(err, "failed to ReadFile"))
 	}
 
-	return nil, system.ErrTenantNotFound
-}
+	sum := sha256.Sum256(contents)
+	hash := sum[:]
 
-// Authentication returns the Authentication for the system.
-func (b *BundleSource) Authentication(ident, namespace string, _ int64) (*tenant.Authentication, error) {
 	b.lock.RLock()
-	defer b.lock.RUnlock()
+	unchanged := b.bundle != nil && bytes.Equal(hash, b.hash)
+	b.lock.RUnlock()
 
-	if b.bundle == nil || b.bundle.TenantConfig.DefaultNamespace.Authentication == nil {
-		return nil, system.ErrTenantNotFound
+	if unchanged {
+		return false, nil
 	}
 
-	if !b.checkIdentifier(ident) {
-		return nil, system.ErrTenantNotFound
+	bdl, err := bundle.ReadBytes(contents, b.readOpts...)
+	if err != nil {
+		return false, b.setLastErr(errors.Wrap(err, "failed to bundle.ReadBytes"))
 	}
 
-	if namespace == "default" {
-		return b.bundle.TenantConfig.DefaultNamespace.Authentication, nil
+	if err := bdl.TenantConfig.Validate(); err != nil {
+		return false, b.setLastErr(errors.Wrapf(errInvalidBundle, "failed to Validate tenant config: %s", err))
 	}
 
-	for _, n := range b.bundle.TenantConfig.Namespaces {
-		if n.Name == namespace {
-			return n.Authentication, nil
-		}
-	}
-
-	return nil, system.ErrTenantNotFound
-}
+	b.lock.Lock()
+	defer b.lock.Unlock()
 
-// Capabilities returns the configuration for the system's capabilities.
-
-func (b *BundleSource) Capabilities(ident, namespace string, _ int64) (*capabilities.CapabilityConfig, error) {
-	defaultConfig := capabilities.DefaultCapabilityConfig()
-
-	b.lock.RLock()
-	defer b.lock.RUnlock()
-
-	if b.bundle == nil || b.bundle.TenantConfig.DefaultNamespace.Capabilities == nil {
-		return &defaultConfig, nil
-	}
-
-	if !b.checkIdentifier(ident) {
-		return &defaultConfig, nil
-	}
+	b.version++
 
-	if namespace == "default" {
-		return b.bundle.TenantConfig.DefaultNamespace.Capabilities, nil
+	var previous *tenant.Config
+	if b.bundle != nil {
+		previous = b.bundle.TenantConfig
 	}
 
-	for _, n := range b.bundle.TenantConfig.Namespaces {
-		if n.Name == namespace {
-			return n.Capabilities, nil
-		}
-	}
+	events := []system.Event{}
 
-	return nil, system.ErrTenantNotFound
-}
+	// if the bundle now contains a different tenant, the previous one's history no longer applies.
+	if previous != nil && previous.Identifier != bdl.TenantConfig.Identifier {
+		b.versions.Remove(previous.Identifier)
 
-// findBundle loops forever until it finds a bundle at the configured path.
-func (b *BundleSource) findBundle() error {
-	for {
-		bdl, err := bundle.Read(b.path)
-		if err != nil {
-			time.Sleep(time.Second)
+		events = append(events, system.Event{
+			Type:          system.EventTenantRemoved,
+			SystemVersion: b.version,
+			Identifier:    previous.Identifier,
+		})
 
-			continue
-		}
+		previous = nil
+	}
 
-		b.lock.Lock()
+	b.versions.Add(bdl.TenantConfig)
 
-		b.bundle = bdl
+	b.bundle = bdl
+	b.hash = hash
+	b.lastErr = nil
 
-		if err := b.bundle.TenantConfig.Validate(); err != nil {
-			return errors.Wrap(err, "failed to Validate tenant config")
-		}
+	b.events.Publish(append(events, system.ConfigEvents(b.version, previous, bdl.TenantConfig)...)...)
 
-		b.lock.Unlock()
+	return true, nil
+}
 
-		break
-	}
+// setLastErr records err as the most recent reload error and returns it.
+func (b *BundleSource) setLastErr(err error) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
 
-	return nil
-}
+	b.lastErr = err
 
-// checkIdentifier checks whether the passed in identifier and version are for the current system running in the
-// bundle or not. Returns true only if both match.
-func (b *BundleSource) checkIdentifier(identifier string) bool {
-	return b.bundle.TenantConfig.Identifier == identifier
+	return err
 }
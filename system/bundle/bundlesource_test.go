@@ -0,0 +1,272 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/suborbital/systemspec/bundle"
+	"github.com/suborbital/systemspec/modulecache"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func writeTestBundle(t *testing.T, path, ident string, tenantVersion int64, modules ...string) {
+	t.Helper()
+
+	conf := tenant.Config{
+		Identifier:    ident,
+		TenantVersion: tenantVersion,
+	}
+
+	files := []os.File{}
+
+	for _, name := range modules {
+		conf.Modules = append(conf.Modules, tenant.Module{Name: name, Namespace: "default", Ref: "abc123"})
+
+		wasmPath := filepath.Join(t.TempDir(), name+".wasm")
+		if err := os.WriteFile(wasmPath, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(wasmPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer f.Close()
+
+		files = append(files, *f)
+	}
+
+	confBytes, err := conf.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bundle.Write(confBytes, files, nil, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundleSourceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+	writeTestBundle(t, path, "com.suborbital.test", 1, "hello")
+
+	source := NewBundleSource(path).(*BundleSource)
+	if err := source.findBundle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	state, _ := source.State()
+	if state.SystemVersion != 1 {
+		t.Errorf("expected SystemVersion 1, got %d", state.SystemVersion)
+	}
+
+	// reloading an unchanged file should not bump the version.
+	if loaded, err := source.reloadBundle(); err != nil || loaded {
+		t.Errorf("unchanged bundle should not reload, loaded: %t, err: %v", loaded, err)
+	}
+
+	writeTestBundle(t, path, "com.suborbital.test", 2, "hello", "goodbye")
+
+	if loaded, err := source.reloadBundle(); err != nil || !loaded {
+		t.Fatalf("changed bundle should reload, loaded: %t, err: %v", loaded, err)
+	}
+
+	ovv, _ := source.Overview()
+	if ovv.SystemVersion != 2 || ovv.TenantRefs.Identifiers["com.suborbital.test"] != 2 {
+		t.Errorf("unexpected overview after reload: %+v", ovv)
+	}
+
+	// an invalid bundle should be reported but the last good bundle kept.
+	writeTestBundle(t, path, "", 3, "hello")
+
+	if _, err := source.reloadBundle(); err == nil {
+		t.Error("invalid bundle should fail to reload")
+	}
+
+	if source.LastError() == nil {
+		t.Error("LastError should report the failed reload")
+	}
+
+	if _, err := source.GetModule("fqmn://com.suborbital.test/default/goodbye@abc123"); err != nil {
+		t.Error("last good bundle should still be served:", err)
+	}
+
+	state, _ = source.State()
+	if state.SystemVersion != 2 {
+		t.Errorf("failed reload should not bump SystemVersion, got %d", state.SystemVersion)
+	}
+
+	// the previous tenant version should remain resolvable.
+	if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+		t.Error("previous version should still be available:", err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 7); !errors.Is(err, system.ErrVersionNotFound) {
+		t.Error("unknown version should return ErrVersionNotFound, got:", err)
+	}
+}
+
+func TestBundleSourceVersionHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+	writeTestBundle(t, path, "com.suborbital.test", 1, "hello")
+
+	source := NewBundleSource(path, WithVersionHistory(1)).(*BundleSource)
+	if err := source.findBundle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestBundle(t, path, "com.suborbital.test", 2, "hello")
+
+	if loaded, err := source.reloadBundle(); err != nil || !loaded {
+		t.Fatalf("changed bundle should reload, loaded: %t, err: %v", loaded, err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 2); err != nil {
+		t.Error("latest version should be available:", err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 1); !errors.Is(err, system.ErrVersionNotFound) {
+		t.Error("version beyond the history should return ErrVersionNotFound, got:", err)
+	}
+}
+
+func TestBundleSourceStaticFileFromMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+
+	conf := tenant.Config{
+		Identifier:    "com.suborbital.test",
+		TenantVersion: 1,
+		Modules:       []tenant.Module{{Name: "hello", Namespace: "default", Ref: "abc123"}},
+	}
+
+	confBytes, err := conf.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"hello.wasm", "index.html"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("hello"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wasmFile, err := os.Open(filepath.Join(dir, "hello.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer wasmFile.Close()
+
+	staticFile, err := os.Open(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer staticFile.Close()
+
+	if err := bundle.Write(confBytes, []os.File{*wasmFile}, map[string]os.File{"index.html": *staticFile}, path); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewBundleSource(path).(*BundleSource)
+	if _, err := source.reloadBundle(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the loaded bundle is served from the bytes that were hashed, not from the file on disk.
+	if err := os.WriteFile(path, []byte("partially written"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := source.bundle.StaticFile("index.html")
+	if err != nil || string(contents) != "hello" {
+		t.Errorf("expected the loaded bundle's static file, got %q, err: %v", contents, err)
+	}
+}
+
+func TestBundleSourceWatchLifetime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+	writeTestBundle(t, path, "com.suborbital.test", 1, "hello")
+
+	source := NewBundleSource(path).(*BundleSource)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := source.StartContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the start context only bounds startup, so the bundle is still watched once it's cancelled.
+	cancel()
+
+	writeTestBundle(t, path, "com.suborbital.test", 2, "hello")
+	waitForVersion(t, source, 2)
+
+	if err := source.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestBundle(t, path, "com.suborbital.test", 3, "hello")
+	time.Sleep(2 * watchInterval)
+
+	if state, _ := source.State(); state.SystemVersion != 2 {
+		t.Errorf("expected the bundle not to be reloaded after Close, got SystemVersion %d", state.SystemVersion)
+	}
+}
+
+func waitForVersion(t *testing.T, source *BundleSource, version int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * watchInterval)
+
+	for time.Now().Before(deadline) {
+		if state, _ := source.State(); state.SystemVersion == version {
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for SystemVersion %d", version)
+}
+
+func TestBundleSourceModuleCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+	writeTestBundle(t, path, "com.suborbital.test", 1, "hello")
+
+	cache, err := modulecache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const FQMN = "fqmn://com.suborbital.test/default/hello@abc123"
+
+	// stale bytes of the same length under the same FQMN, as left behind by a rebuilt bundle.
+	if err := cache.Put(FQMN, []byte("HELLO")); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewBundleSource(path, WithModuleCache(cache)).(*BundleSource)
+	if _, err := source.reloadBundle(); err != nil {
+		t.Fatal(err)
+	}
+
+	mod, err := source.GetModule(FQMN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(mod.WasmRef.Data) != "hello" {
+		t.Errorf("expected the bundle's bytes rather than the stale cached ones, got %q", mod.WasmRef.Data)
+	}
+
+	if data, err := cache.Get(FQMN); err != nil || string(data) != "hello" {
+		t.Errorf("expected the cache to be updated with the bundle's bytes, got %q (%v)", data, err)
+	}
+}
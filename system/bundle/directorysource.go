@@ -0,0 +1,127 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/bundle"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// bundleSuffix is the file extension used to find bundles within a directory.
+const bundleSuffix = ".wasm.zip"
+
+// DirectorySource is a Source backed by a directory of bundle files, serving every tenant (and tenant version)
+// found within it. Tenant-specific calls return system.ErrVersionNotFound for versions not present in the directory.
+type DirectorySource struct {
+	versionedSource
+
+	path string
+}
+
+// NewDirectorySource creates a new DirectorySource that loads every bundle in the directory at [path].
+func NewDirectorySource(path string, opts ...Option) system.Source {
+	d := &DirectorySource{
+		versionedSource: newVersionedSource(0, opts),
+		path:            path,
+	}
+
+	return d
+}
+
+// Start initializes the system source.
+func (d *DirectorySource) Start() error {
+	return d.StartContext(context.Background())
+}
+
+// StartContext initializes the system source, waiting until the directory exists or ctx is done.
+func (d *DirectorySource) StartContext(ctx context.Context) error {
+	if err := d.findBundles(ctx); err != nil {
+		return errors.Wrap(err, "failed to findBundles")
+	}
+
+	return nil
+}
+
+// State returns the state of the entire system.
+func (_ *DirectorySource) State() (*system.State, error) {
+	s := &system.State{
+		SystemVersion: 1,
+	}
+
+	return s, nil
+}
+
+// Overview gets the overview for the entire system.
+func (d *DirectorySource) Overview() (*system.Overview, error) {
+	ovv := &system.Overview{
+		State: system.State{
+			SystemVersion: 1,
+		},
+		TenantRefs: system.References{
+			Identifiers: d.versions.Identifiers(),
+		},
+	}
+
+	return ovv, nil
+}
+
+// findBundles loops until the configured directory exists or ctx is done, and then loads every bundle within it.
+func (d *DirectorySource) findBundles(ctx context.Context) error {
+	for {
+		if _, err := os.Stat(d.path); err == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to ReadDir")
+	}
+
+	configs := []*tenant.Config{}
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), bundleSuffix) {
+			continue
+		}
+
+		bdl, err := bundle.Read(filepath.Join(d.path, entry.Name()), d.readOpts...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to bundle.Read %s", entry.Name())
+		}
+
+		if err := bdl.TenantConfig.Validate(); err != nil {
+			return errors.Wrapf(err, "failed to Validate tenant config in %s", entry.Name())
+		}
+
+		key := fmt.Sprintf("%s@%d", bdl.TenantConfig.Identifier, bdl.TenantConfig.TenantVersion)
+		if seen[key] {
+			return fmt.Errorf("found more than one bundle for tenant %s version %d", bdl.TenantConfig.Identifier, bdl.TenantConfig.TenantVersion)
+		}
+
+		seen[key] = true
+
+		configs = append(configs, bdl.TenantConfig)
+	}
+
+	for _, c := range configs {
+		d.versions.Add(c)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package bundle
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/suborbital/systemspec/system"
+)
+
+func TestDirectorySource(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestBundle(t, filepath.Join(dir, "one-v1.wasm.zip"), "com.suborbital.one", 1, "hello")
+	writeTestBundle(t, filepath.Join(dir, "one-v2.wasm.zip"), "com.suborbital.one", 2, "hello", "goodbye")
+	writeTestBundle(t, filepath.Join(dir, "two-v5.wasm.zip"), "com.suborbital.two", 5, "hello")
+
+	source := NewDirectorySource(dir)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ovv, err := source.Overview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ovv.TenantRefs.Identifiers) != 2 || ovv.TenantRefs.Identifiers["com.suborbital.one"] != 2 || ovv.TenantRefs.Identifiers["com.suborbital.two"] != 5 {
+		t.Errorf("unexpected tenant refs: %v", ovv.TenantRefs.Identifiers)
+	}
+
+	tovv, err := source.TenantOverview("com.suborbital.one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tovv.Version != 2 || len(tovv.Config.Modules) != 2 {
+		t.Errorf("expected latest version of tenant, got version %d", tovv.Version)
+	}
+
+	if _, err := source.Workflows("com.suborbital.one", "default", 1); err != nil {
+		t.Error("version 1 should be available:", err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.one", "default", 3); err == nil {
+		t.Error("version 3 should not be available")
+	}
+
+	if _, err := source.Capabilities("com.suborbital.two", "default", 5); err != nil {
+		t.Error("capabilities should be available:", err)
+	}
+
+	if _, err := source.Capabilities("com.suborbital.missing", "default", 1); !errors.Is(err, system.ErrTenantNotFound) {
+		t.Error("expected ErrTenantNotFound for the capabilities of an unknown tenant, got:", err)
+	}
+
+	if _, err := source.GetModule("fqmn://com.suborbital.two/default/hello@abc123"); err != nil {
+		t.Error("module should be found:", err)
+	}
+
+	if _, err := source.GetModule("fqmn://com.suborbital.two/default/goodbye@abc123"); err == nil {
+		t.Error("module should not be found in another tenant")
+	}
+
+	limited := NewDirectorySource(dir, WithVersionHistory(1))
+	if err := limited.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := limited.Workflows("com.suborbital.one", "default", 1); err == nil {
+		t.Error("version 1 should not be kept with a history of 1")
+	}
+
+	if _, err := limited.Workflows("com.suborbital.one", "default", 2); err != nil {
+		t.Error("version 2 should be available:", err)
+	}
+}
@@ -0,0 +1,127 @@
+package bundle
+
+import (
+	"github.com/suborbital/systemspec/bundle"
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/modulecache"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// Option configures a BundleSource or DirectorySource.
+type Option func(*versionedSource)
+
+// WithModuleCache configures the source to read module bytes from [cache] when they are unchanged,
+// and to store the bytes of the others in it (see bundle.WithModuleCache).
+func WithModuleCache(cache *modulecache.Cache) Option {
+	return func(v *versionedSource) {
+		v.readOpts = append(v.readOpts, bundle.WithModuleCache(cache))
+	}
+}
+
+// WithVersionHistory sets the number of versions of each tenant that the source keeps, including the latest.
+// A history of zero or less keeps every version. BundleSource defaults to system.DefaultVersionHistory, and
+// DirectorySource to every version found in its directory.
+func WithVersionHistory(n int) Option {
+	return func(v *versionedSource) {
+		v.history = n
+	}
+}
+
+// versionedSource implements the tenant-specific parts of system.Source on top of a
+// VersionStore, and is shared by the bundle-backed sources. Versions are resolved by
+// VersionStore.Get, so system.LatestVersion (zero) requests the latest version.
+type versionedSource struct {
+	versions *system.VersionStore
+
+	// history is the number of versions of each tenant that versions keeps.
+	history int
+
+	// readOpts are used for every bundle that is read.
+	readOpts []bundle.ReadOption
+}
+
+// newVersionedSource returns a versionedSource configured by [opts], keeping [history] versions of each tenant by default.
+func newVersionedSource(history int, opts []Option) versionedSource {
+	v := versionedSource{history: history}
+
+	for _, opt := range opts {
+		opt(&v)
+	}
+
+	v.versions = system.NewVersionStore(v.history)
+
+	return v
+}
+
+// TenantOverview gets the overview for the latest version of the given tenant.
+func (v *versionedSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	config, err := v.versions.Latest(ident)
+	if err != nil {
+		return nil, err
+	}
+
+	ovv := &system.TenantOverview{
+		Identifier: ident,
+		Version:    config.TenantVersion,
+		Config:     config,
+	}
+
+	return ovv, nil
+}
+
+// GetModule searches for and returns the requested module
+// otherwise system.ErrModuleNotFound.
+func (v *versionedSource) GetModule(FQMN string) (*tenant.Module, error) {
+	return v.versions.GetModule(FQMN)
+}
+
+// Workflows returns the workflows for the given tenant version.
+func (v *versionedSource) Workflows(ident, namespace string, version int64) ([]tenant.Workflow, error) {
+	nc, err := v.versions.Namespace(ident, namespace, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return nc.Workflows, nil
+}
+
+// Connections returns the Connections for the given tenant version.
+func (v *versionedSource) Connections(ident, namespace string, version int64) ([]tenant.Connection, error) {
+	nc, err := v.versions.Namespace(ident, namespace, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return nc.Connections, nil
+}
+
+// Authentication returns the Authentication for the given tenant version.
+func (v *versionedSource) Authentication(ident, namespace string, version int64) (*tenant.Authentication, error) {
+	nc, err := v.versions.Namespace(ident, namespace, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if nc.Authentication == nil {
+		return nil, system.ErrTenantNotFound
+	}
+
+	return nc.Authentication, nil
+}
+
+// Capabilities returns the configuration for the given tenant version's capabilities, or the default
+// configuration if it doesn't configure any. An unknown tenant is ErrTenantNotFound, like everywhere else.
+func (v *versionedSource) Capabilities(ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
+	nc, err := v.versions.Namespace(ident, namespace, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if nc.Capabilities == nil {
+		defaultConfig := capabilities.DefaultCapabilityConfig()
+		return &defaultConfig, nil
+	}
+
+	return nc.Capabilities, nil
+}
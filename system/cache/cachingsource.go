@@ -0,0 +1,373 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// stateKey is the key that the system state is persisted under.
+const stateKey = "state"
+
+// stateMaxAge is how long responses are served from the cache before the wrapped source's State
+// is checked again, for callers that don't poll State themselves.
+const stateMaxAge = 5 * time.Second
+
+// CachingSource is a Source that wraps another Source, memoizing its responses until the
+// system version changes and falling back to the last known responses when it is unavailable.
+// If configured with a directory, responses are also persisted there so that they survive restarts.
+type CachingSource struct {
+	source system.Source
+	dir    string
+
+	systemVersion int64
+	hasState      bool
+	memo          map[string]any
+
+	// generation is incremented whenever the memo is invalidated, so that fetches that started
+	// before an invalidation don't memoize their possibly stale results.
+	generation uint64
+
+	// checked is when the wrapped source's State was last requested.
+	checked time.Time
+
+	// stop cancels the background start of the wrapped source, if there is one.
+	stop context.CancelFunc
+
+	lock sync.RWMutex
+}
+
+// NewCachingSource creates a new CachingSource wrapping [source]. If [dir] is not empty,
+// responses are persisted to that directory.
+func NewCachingSource(source system.Source, dir string) system.Source {
+	c := &CachingSource{
+		source: source,
+		dir:    dir,
+		memo:   map[string]any{},
+		lock:   sync.RWMutex{},
+	}
+
+	return c
+}
+
+// Start initializes the system source. If a previously persisted state exists, the wrapped
+// source is started in the background so that cached responses can be served immediately.
+func (c *CachingSource) Start() error {
+	return c.StartContext(context.Background())
+}
+
+// StartContext initializes the system source as Start does. If the wrapped source is started in the background,
+// that continues after ctx is done, until Close is called.
+func (c *CachingSource) StartContext(ctx context.Context) error {
+	source := system.WithContext(c.source)
+
+	if c.dir != "" {
+		if err := os.MkdirAll(c.dir, 0700); err != nil {
+			return errors.Wrap(err, "failed to MkdirAll")
+		}
+
+		state := &system.State{}
+		if c.readDisk(c.diskPath(stateKey), state) {
+			startCtx, stop := context.WithCancel(context.Background())
+
+			c.lock.Lock()
+			c.systemVersion = state.SystemVersion
+			c.hasState = true
+			c.stop = stop
+			c.lock.Unlock()
+
+			// errors cannot be surfaced here, and any failure will be retried by calls to the wrapped source.
+			go func() { _ = source.StartContext(startCtx) }()
+
+			return nil
+		}
+	}
+
+	if err := source.StartContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to source.StartContext")
+	}
+
+	return nil
+}
+
+// Close stops the background start of the wrapped source, if it is still running, and closes the wrapped source.
+func (c *CachingSource) Close() error {
+	c.lock.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.lock.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+
+	return system.Close(c.source)
+}
+
+// State returns the state of the entire system, invalidating cached responses if the system version has changed.
+// State is also checked by the other methods whenever it hasn't been for stateMaxAge, so callers that never poll
+// State still see changes.
+func (c *CachingSource) State() (*system.State, error) {
+	return c.StateContext(context.Background())
+}
+
+// StateContext is State with a context that is passed to the wrapped source.
+func (c *CachingSource) StateContext(ctx context.Context) (*system.State, error) {
+	state, err := system.WithContext(c.source).StateContext(ctx)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.checked = time.Now()
+
+	if err != nil {
+		if !c.hasState || ctx.Err() != nil {
+			return nil, errors.Wrap(err, "failed to source.State")
+		}
+
+		return &system.State{SystemVersion: c.systemVersion}, nil
+	}
+
+	if !c.hasState || state.SystemVersion != c.systemVersion {
+		c.memo = map[string]any{}
+		c.generation++
+		c.systemVersion = state.SystemVersion
+		c.hasState = true
+
+		c.writeDisk(c.diskPath(stateKey), state)
+		c.pruneDisk()
+	}
+
+	return state, nil
+}
+
+// revalidate checks the wrapped source's State if it hasn't been checked for stateMaxAge.
+func (c *CachingSource) revalidate(ctx context.Context) {
+	c.lock.RLock()
+	fresh := c.hasState && time.Since(c.checked) < stateMaxAge
+	c.lock.RUnlock()
+
+	if !fresh {
+		// failures are handled by State, which keeps serving the last known version.
+		_, _ = c.StateContext(ctx)
+	}
+}
+
+// Overview gets the overview for the entire system.
+func (c *CachingSource) Overview() (*system.Overview, error) {
+	return c.OverviewContext(context.Background())
+}
+
+// OverviewContext gets the overview for the entire system.
+func (c *CachingSource) OverviewContext(ctx context.Context) (*system.Overview, error) {
+	return cached(ctx, c, "overview", func(source system.ContextSource) (*system.Overview, error) {
+		return source.OverviewContext(ctx)
+	})
+}
+
+// TenantOverview gets the overview for a given tenant.
+func (c *CachingSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	return c.TenantOverviewContext(context.Background(), ident)
+}
+
+// TenantOverviewContext gets the overview for a given tenant.
+func (c *CachingSource) TenantOverviewContext(ctx context.Context, ident string) (*system.TenantOverview, error) {
+	return cached(ctx, c, fmt.Sprintf("tenant/%s", ident), func(source system.ContextSource) (*system.TenantOverview, error) {
+		return source.TenantOverviewContext(ctx, ident)
+	})
+}
+
+// GetModule returns the requested module.
+func (c *CachingSource) GetModule(FQMN string) (*tenant.Module, error) {
+	return c.GetModuleContext(context.Background(), FQMN)
+}
+
+// GetModuleContext returns the requested module.
+func (c *CachingSource) GetModuleContext(ctx context.Context, FQMN string) (*tenant.Module, error) {
+	return cached(ctx, c, fmt.Sprintf("module/%s", FQMN), func(source system.ContextSource) (*tenant.Module, error) {
+		return source.GetModuleContext(ctx, FQMN)
+	})
+}
+
+// Workflows returns the Workflows for the system.
+func (c *CachingSource) Workflows(ident, namespace string, version int64) ([]tenant.Workflow, error) {
+	return c.WorkflowsContext(context.Background(), ident, namespace, version)
+}
+
+// WorkflowsContext returns the Workflows for the system.
+func (c *CachingSource) WorkflowsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Workflow, error) {
+	return cached(ctx, c, fmt.Sprintf("workflows/%s/%s/%d", ident, namespace, version), func(source system.ContextSource) ([]tenant.Workflow, error) {
+		return source.WorkflowsContext(ctx, ident, namespace, version)
+	})
+}
+
+// Connections returns the Connections for the system.
+func (c *CachingSource) Connections(ident, namespace string, version int64) ([]tenant.Connection, error) {
+	return c.ConnectionsContext(context.Background(), ident, namespace, version)
+}
+
+// ConnectionsContext returns the Connections for the system.
+func (c *CachingSource) ConnectionsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Connection, error) {
+	return cached(ctx, c, fmt.Sprintf("connections/%s/%s/%d", ident, namespace, version), func(source system.ContextSource) ([]tenant.Connection, error) {
+		return source.ConnectionsContext(ctx, ident, namespace, version)
+	})
+}
+
+// Authentication returns the Authentication for the system.
+func (c *CachingSource) Authentication(ident, namespace string, version int64) (*tenant.Authentication, error) {
+	return c.AuthenticationContext(context.Background(), ident, namespace, version)
+}
+
+// AuthenticationContext returns the Authentication for the system.
+func (c *CachingSource) AuthenticationContext(ctx context.Context, ident, namespace string, version int64) (*tenant.Authentication, error) {
+	return cached(ctx, c, fmt.Sprintf("authentication/%s/%s/%d", ident, namespace, version), func(source system.ContextSource) (*tenant.Authentication, error) {
+		return source.AuthenticationContext(ctx, ident, namespace, version)
+	})
+}
+
+// Capabilities returns the Capabilities for the system.
+func (c *CachingSource) Capabilities(ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
+	return c.CapabilitiesContext(context.Background(), ident, namespace, version)
+}
+
+// CapabilitiesContext returns the Capabilities for the system.
+func (c *CachingSource) CapabilitiesContext(ctx context.Context, ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
+	return cached(ctx, c, fmt.Sprintf("caps/%s/%s/%d", ident, namespace, version), func(source system.ContextSource) (*capabilities.CapabilityConfig, error) {
+		return source.CapabilitiesContext(ctx, ident, namespace, version)
+	})
+}
+
+// cached returns the memoized response for key if there is one, otherwise calls fetch and memoizes the result.
+// If fetch fails for any reason other than the requested entity not existing, the response persisted for the
+// current system version is used instead.
+func cached[T any](ctx context.Context, c *CachingSource, key string, fetch func(source system.ContextSource) (T, error)) (T, error) {
+	c.revalidate(ctx)
+
+	c.lock.RLock()
+	val, exists := c.memo[key]
+	generation, version := c.generation, c.systemVersion
+	c.lock.RUnlock()
+
+	if exists {
+		return val.(T), nil
+	}
+
+	result, err := fetch(system.WithContext(c.source))
+	if err != nil {
+		var persisted T
+
+		if isNotFound(err) || ctx.Err() != nil || !c.readDisk(c.responsePath(version, key), &persisted) {
+			return result, err
+		}
+
+		return persisted, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// the cache was invalidated during the fetch, so the result may predate the new system version.
+	if c.generation != generation {
+		return result, nil
+	}
+
+	c.memo[key] = result
+	c.writeDisk(c.responsePath(version, key), result)
+
+	return result, nil
+}
+
+// isNotFound returns true if err indicates that the wrapped source authoritatively does not have the requested entity.
+func isNotFound(err error) bool {
+	return errors.Is(err, system.ErrTenantNotFound) ||
+		errors.Is(err, system.ErrNamespaceNotFound) ||
+		errors.Is(err, system.ErrVersionNotFound) ||
+		errors.Is(err, system.ErrModuleNotFound)
+}
+
+// readDisk reads the persisted response at path into dest, returning false if there isn't one.
+func (c *CachingSource) readDisk(path string, dest any) bool {
+	if c.dir == "" {
+		return false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, dest) == nil
+}
+
+// writeDisk persists a response to path. Failures are ignored as the disk is only used as a fallback.
+func (c *CachingSource) writeDisk(path string, val any) {
+	if c.dir == "" {
+		return
+	}
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+
+	// write to a temporary file and rename it so that a crash never leaves a partially written response.
+	tmpPath := path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return
+	}
+
+	_ = os.Rename(tmpPath, path)
+}
+
+// pruneDisk removes the responses persisted for every system version other than the current one,
+// so that a response from an older version is never served as part of the current one. The lock must be held.
+func (c *CachingSource) pruneDisk() {
+	if c.dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	current := strconv.FormatInt(c.systemVersion, 10)
+
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != current {
+			_ = os.RemoveAll(filepath.Join(c.dir, e.Name()))
+		}
+	}
+}
+
+// diskPath returns the path that the value for key is persisted to.
+func (c *CachingSource) diskPath(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.dir, fmt.Sprintf("%s.json", hex.EncodeToString(hash[:])))
+}
+
+// responsePath returns the path that the response for key is persisted to for the given system version.
+func (c *CachingSource) responsePath(version int64, key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.dir, strconv.FormatInt(version, 10), fmt.Sprintf("%s.json", hex.EncodeToString(hash[:])))
+}
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+var errUnavailable = errors.New("control plane unavailable")
+
+// fakeSource counts calls to Workflows and can be made to fail every call.
+type fakeSource struct {
+	version   int64
+	down      bool
+	workflows int
+
+	// during, if set, is called in the middle of each call to Workflows.
+	during func()
+}
+
+func (f *fakeSource) Start() error { return nil }
+
+func (f *fakeSource) State() (*system.State, error) {
+	if f.down {
+		return nil, errUnavailable
+	}
+
+	return &system.State{SystemVersion: f.version}, nil
+}
+
+func (f *fakeSource) Overview() (*system.Overview, error) { return nil, errUnavailable }
+
+func (f *fakeSource) TenantOverview(string) (*system.TenantOverview, error) {
+	return nil, system.ErrTenantNotFound
+}
+
+func (f *fakeSource) GetModule(string) (*tenant.Module, error) { return nil, errUnavailable }
+
+func (f *fakeSource) Workflows(string, string, int64) ([]tenant.Workflow, error) {
+	if f.down {
+		return nil, errUnavailable
+	}
+
+	f.workflows++
+
+	if f.during != nil {
+		f.during()
+	}
+
+	return []tenant.Workflow{{Name: "wf"}}, nil
+}
+
+func (f *fakeSource) Connections(string, string, int64) ([]tenant.Connection, error) {
+	return nil, errUnavailable
+}
+
+func (f *fakeSource) Authentication(string, string, int64) (*tenant.Authentication, error) {
+	return nil, errUnavailable
+}
+
+func (f *fakeSource) Capabilities(string, string, int64) (*capabilities.CapabilityConfig, error) {
+	return nil, errUnavailable
+}
+
+func TestCachingSourceMemoizes(t *testing.T) {
+	upstream := &fakeSource{version: 1}
+	source := NewCachingSource(upstream, "")
+
+	if _, err := source.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if upstream.workflows != 1 {
+		t.Errorf("expected 1 upstream call, got %d", upstream.workflows)
+	}
+
+	upstream.version = 2
+
+	if _, err := source.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if upstream.workflows != 2 {
+		t.Errorf("expected system version change to invalidate the cache, got %d upstream calls", upstream.workflows)
+	}
+
+	if _, err := source.TenantOverview("com.suborbital.other"); !errors.Is(err, system.ErrTenantNotFound) {
+		t.Error("expected ErrTenantNotFound to pass through, got:", err)
+	}
+}
+
+func TestCachingSourcePersists(t *testing.T) {
+	dir := t.TempDir()
+
+	upstream := &fakeSource{version: 4}
+	source := NewCachingSource(upstream, dir)
+
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a restart during a control plane outage.
+	upstream.down = true
+	restarted := NewCachingSource(upstream, dir)
+
+	if err := restarted.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := restarted.State()
+	if err != nil || state.SystemVersion != 4 {
+		t.Fatalf("expected persisted state with version 4, got %v (%v)", state, err)
+	}
+
+	workflows, err := restarted.Workflows("com.suborbital.test", "default", 1)
+	if err != nil || len(workflows) != 1 || workflows[0].Name != "wf" {
+		t.Errorf("expected persisted workflows, got %v (%v)", workflows, err)
+	}
+
+	if _, err := restarted.Workflows("com.suborbital.test", "default", 2); !errors.Is(err, errUnavailable) {
+		t.Error("expected an uncached request to fail, got:", err)
+	}
+
+	// once the system version changes, responses persisted for the previous version are no longer served.
+	upstream.down = false
+	upstream.version = 5
+
+	if _, err := restarted.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	upstream.down = true
+	restarted = NewCachingSource(upstream, dir)
+
+	if err := restarted.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := restarted.Workflows("com.suborbital.test", "default", 1); !errors.Is(err, errUnavailable) {
+		t.Error("expected a response persisted for a previous version to be ignored, got:", err)
+	}
+}
+
+func TestCachingSourceInvalidation(t *testing.T) {
+	upstream := &fakeSource{version: 1}
+	source := NewCachingSource(upstream, "").(*CachingSource)
+
+	if _, err := source.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the system changes while the first fetch is in flight, so its result must not be memoized.
+	upstream.during = func() {
+		upstream.during = nil
+		upstream.version = 2
+
+		if _, err := source.State(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if upstream.workflows != 2 {
+		t.Errorf("expected a result fetched across an invalidation to be refetched, got %d upstream calls", upstream.workflows)
+	}
+
+	// the cache is invalidated once State is stale, even if the caller never polls it.
+	upstream.version = 3
+
+	source.lock.Lock()
+	source.checked = time.Now().Add(-stateMaxAge)
+	source.lock.Unlock()
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if upstream.workflows != 3 {
+		t.Errorf("expected a stale State to invalidate the cache, got %d upstream calls", upstream.workflows)
+	}
+}
+
+func TestCachingSourceContext(t *testing.T) {
+	upstream := &fakeSource{version: 1}
+	source := NewCachingSource(upstream, t.TempDir()).(*CachingSource)
+
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a cancelled request is reported as such rather than served from the disk fallback.
+	if _, err := source.WorkflowsContext(ctx, "com.suborbital.test", "default", 2); !errors.Is(err, context.Canceled) {
+		t.Error("expected context.Canceled, got:", err)
+	}
+}
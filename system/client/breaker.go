@@ -0,0 +1,93 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/system"
+)
+
+var errCircuitOpen = errors.Wrap(system.ErrUnavailable, "circuit breaker is open")
+
+// breaker is a circuit breaker that opens after a number of consecutive failures. A nil breaker always allows requests.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	failures  int
+	openUntil time.Time
+	trial     bool
+
+	lock sync.Mutex
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	b := &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		lock:      sync.Mutex{},
+	}
+
+	return b
+}
+
+// allow returns errCircuitOpen if requests should not currently be made. Once the cooldown has passed,
+// a single trial request is allowed through to determine whether the circuit should close.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if time.Now().Before(b.openUntil) || b.trial {
+		return errCircuitOpen
+	}
+
+	b.trial = true
+
+	return nil
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+
+	if err == nil || !errors.Is(err, system.ErrUnavailable) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release ends a trial request without recording its outcome, such as when the caller cancelled it,
+// so that the next request becomes the trial instead.
+func (b *breaker) release() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,12 +9,14 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/suborbital/systemspec/capabilities"
 	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/modulecache"
 	"github.com/suborbital/systemspec/system"
 	"github.com/suborbital/systemspec/tenant"
 )
@@ -25,10 +28,19 @@ type HTTPSource struct {
 	host       string
 	authHeader string
 	client     *http.Client
+	retry      RetryPolicy
+	breaker    *breaker
+	modules    *modulecache.Cache
+
+	// supplier, if set, provides the credential (and therefore authHeader) on demand.
+	supplier   system.CredentialSupplier
+	credential system.Credential
+	authLock   sync.Mutex
 }
 
 // NewHTTPSource creates a new HTTPSource that looks for a bundle at [host].
-func NewHTTPSource(hostIn string, creds system.Credential) system.Source {
+// By default each request is attempted once and no circuit breaker is used.
+func NewHTTPSource(hostIn string, creds system.Credential, opts ...Option) system.Source {
 	host := hostIn
 	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
 		host = fmt.Sprintf("http://%s", host)
@@ -39,18 +51,30 @@ func NewHTTPSource(hostIn string, creds system.Credential) system.Source {
 		client: &http.Client{
 			Timeout: defaultTimeout,
 		},
+		retry: RetryPolicy{
+			MaxAttempts: 1,
+		},
 	}
 
 	if creds != nil {
 		source.authHeader = fmt.Sprintf("%s %s", creds.Scheme(), creds.Value())
 	}
 
+	for _, opt := range opts {
+		opt(source)
+	}
+
 	return source
 }
 
 // Start initializes the system source.
 func (h *HTTPSource) Start() error {
-	if err := h.pingServer(); err != nil {
+	return h.StartContext(context.Background())
+}
+
+// StartContext initializes the system source, waiting until the server is reachable or ctx is done.
+func (h *HTTPSource) StartContext(ctx context.Context) error {
+	if err := h.pingServer(ctx); err != nil {
 		return errors.Wrap(err, "failed to pingServer")
 	}
 
@@ -59,8 +83,13 @@ func (h *HTTPSource) Start() error {
 
 // State returns the state of the entire system.
 func (h *HTTPSource) State() (*system.State, error) {
+	return h.StateContext(context.Background())
+}
+
+// StateContext returns the state of the entire system.
+func (h *HTTPSource) StateContext(ctx context.Context) (*system.State, error) {
 	s := &system.State{}
-	if err := h.get("/system/v1/state", s); err != nil {
+	if err := h.get(ctx, "/system/v1/state", s); err != nil {
 		return nil, errors.Wrap(err, "failed to get /state")
 	}
 
@@ -69,19 +98,44 @@ func (h *HTTPSource) State() (*system.State, error) {
 
 // Overview gets the overview for the entire system.
 func (h *HTTPSource) Overview() (*system.Overview, error) {
+	return h.OverviewContext(context.Background())
+}
+
+// OverviewContext gets the overview for the entire system.
+func (h *HTTPSource) OverviewContext(ctx context.Context) (*system.Overview, error) {
 	ovv := &system.Overview{}
-	if err := h.get("/system/v1/overview", ovv); err != nil {
+	if err := h.get(ctx, "/system/v1/overview", ovv); err != nil {
 		return nil, errors.Wrap(err, "failed to get /overview")
 	}
 
 	return ovv, nil
 }
 
+// OverviewSince gets the changes to the system's tenants since the given system version.
+func (h *HTTPSource) OverviewSince(since int64) (*system.OverviewDelta, error) {
+	return h.OverviewSinceContext(context.Background(), since)
+}
+
+// OverviewSinceContext gets the changes to the system's tenants since the given system version.
+func (h *HTTPSource) OverviewSinceContext(ctx context.Context, since int64) (*system.OverviewDelta, error) {
+	delta := &system.OverviewDelta{}
+	if err := h.get(ctx, fmt.Sprintf("/system/v1/overview/delta/%d", since), delta); err != nil {
+		return nil, errors.Wrap(err, "failed to get /overview/delta")
+	}
+
+	return delta, nil
+}
+
 // TenantOverview gets the overview for a given tenant.
 func (h *HTTPSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	return h.TenantOverviewContext(context.Background(), ident)
+}
+
+// TenantOverviewContext gets the overview for a given tenant.
+func (h *HTTPSource) TenantOverviewContext(ctx context.Context, ident string) (*system.TenantOverview, error) {
 	ovv := &system.TenantOverview{}
 
-	if err := h.get(fmt.Sprintf("/system/v1/tenant/%s", ident), ovv); err != nil {
+	if err := h.get(ctx, fmt.Sprintf("/system/v1/tenant/%s", ident), ovv); err != nil {
 		return nil, errors.Wrap(err, "failed to get tenant overview")
 	}
 
@@ -90,32 +144,127 @@ func (h *HTTPSource) TenantOverview(ident string) (*system.TenantOverview, error
 
 // GetModule returns a nil error if a Module with the
 // provided FQMN can be made available at the next sync,
-// otherwise ErrRunnableNotFound is returned.
+// otherwise ErrModuleNotFound is returned.
 func (h *HTTPSource) GetModule(FQMN string) (*tenant.Module, error) {
+	return h.GetModuleContext(context.Background(), FQMN)
+}
+
+// GetModuleContext returns the Module with the provided FQMN, otherwise ErrModuleNotFound is returned.
+// If a module cache is configured, the module's bytes are fetched separately and only downloaded again when their ETag changes.
+func (h *HTTPSource) GetModuleContext(ctx context.Context, FQMN string) (*tenant.Module, error) {
 	f, err := fqmn.Parse(FQMN)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to Parse FQMN")
 	}
 
 	path := fmt.Sprintf("/system/v1/module%s", f.URLPath())
+	if h.modules != nil {
+		path += "?data=false"
+	}
 
 	module := &tenant.Module{}
-	if err := h.authedGet(path, h.authHeader, module); err != nil {
-		if errors.Is(err, system.ErrAuthenticationFailed) {
-			return nil, errors.Wrap(err, system.ErrAuthenticationFailed.Error())
-		}
+	if err := h.authedGet(ctx, path, module, system.ErrModuleNotFound); err != nil {
+		return nil, errors.Wrap(err, "failed to get /module")
+	}
+
+	if h.modules == nil || module.WasmRef == nil {
+		return module, nil
+	}
 
-		return nil, errors.Wrap(err, system.ErrModuleNotFound.Error())
+	if module.WasmRef.Data != nil {
+		// the server doesn't support omitting the data, so there is nothing to gain from the cache.
+		return module, nil
 	}
 
+	data, err := h.readCachedModule(ctx, FQMN)
+	if err != nil {
+		return nil, err
+	}
+
+	module.WasmRef.Data = data
+
 	return module, nil
 }
 
+// readCachedModule returns the contents of a module, revalidating any cached bytes against the server using the ETag
+// they were served with, so that a module rebuilt under the same FQMN is downloaded again.
+func (h *HTTPSource) readCachedModule(ctx context.Context, FQMN string) ([]byte, error) {
+	// the bytes are cached under their ETag and the latest ETag under the FQMN, so the two can never disagree.
+	etag := ""
+	var cached []byte
+
+	if tag, err := h.modules.Get(FQMN); err == nil {
+		if data, err := h.modules.Get(taggedRef(FQMN, string(tag))); err == nil {
+			etag, cached = string(tag), data
+		}
+	}
+
+	stream, err := h.ReadModule(ctx, FQMN, etag)
+	if err != nil {
+		if errors.Is(err, system.ErrModuleNotModified) {
+			return cached, nil
+		}
+
+		return nil, err
+	}
+
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, unavailable(err, "failed to ReadAll module")
+	}
+
+	// a failure to write to the cache only means the module will be downloaded again.
+	if stream.ETag != "" {
+		if err := h.modules.Put(taggedRef(FQMN, stream.ETag), data); err == nil {
+			_ = h.modules.Put(FQMN, []byte(stream.ETag))
+		}
+	}
+
+	return data, nil
+}
+
+// taggedRef returns the module cache ref under which the bytes of a module served with the given ETag are stored.
+func taggedRef(FQMN, etag string) string {
+	return FQMN + "#" + etag
+}
+
+// ReadModule streams the Wasm bytes of the module with the provided FQMN from the server's /wasm endpoint, which
+// the caller must Close. If etag is not empty and the module is unchanged, system.ErrModuleNotModified is returned.
+// The configured timeout applies to receiving the response headers, not to reading the stream.
+func (h *HTTPSource) ReadModule(ctx context.Context, FQMN, etag string) (*system.ModuleData, error) {
+	f, err := fqmn.Parse(FQMN)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Parse FQMN")
+	}
+
+	var data *system.ModuleData
+
+	err = h.withRetries(ctx, fmt.Sprintf("/system/v1/wasm%s", f.URLPath()), func(reqURL, auth string) error {
+		var err error
+		data, err = h.attemptStream(ctx, reqURL, auth, etag)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get /wasm")
+	}
+
+	return data, nil
+}
+
 // Workflows returns the Workflows for the system.
 func (h *HTTPSource) Workflows(ident, namespace string, version int64) ([]tenant.Workflow, error) {
+	return h.WorkflowsContext(context.Background(), ident, namespace, version)
+}
+
+// WorkflowsContext returns the Workflows for the system.
+func (h *HTTPSource) WorkflowsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Workflow, error) {
 	workflows := make([]tenant.Workflow, 0)
 
-	if err := h.get(fmt.Sprintf("/system/v1/workflows/%s/%s/%d", ident, namespace, version), &workflows); err != nil {
+	if err := h.get(ctx, fmt.Sprintf("/system/v1/workflows/%s/%s/%d", ident, namespace, version), &workflows); err != nil {
 		return nil, errors.Wrap(err, "failed to get /schedules")
 	}
 
@@ -124,9 +273,14 @@ func (h *HTTPSource) Workflows(ident, namespace string, version int64) ([]tenant
 
 // Connections returns the Connections for the system.
 func (h *HTTPSource) Connections(ident, namespace string, version int64) ([]tenant.Connection, error) {
+	return h.ConnectionsContext(context.Background(), ident, namespace, version)
+}
+
+// ConnectionsContext returns the Connections for the system.
+func (h *HTTPSource) ConnectionsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Connection, error) {
 	connections := make([]tenant.Connection, 0)
 
-	if err := h.get(fmt.Sprintf("/system/v1/connections/%s/%s/%d", ident, namespace, version), &connections); err != nil {
+	if err := h.get(ctx, fmt.Sprintf("/system/v1/connections/%s/%s/%d", ident, namespace, version), &connections); err != nil {
 		return nil, errors.Wrap(err, "failed to get /connections")
 	}
 
@@ -135,9 +289,14 @@ func (h *HTTPSource) Connections(ident, namespace string, version int64) ([]tena
 
 // Authentication returns the Authentication for the system.
 func (h *HTTPSource) Authentication(ident, namespace string, version int64) (*tenant.Authentication, error) {
+	return h.AuthenticationContext(context.Background(), ident, namespace, version)
+}
+
+// AuthenticationContext returns the Authentication for the system.
+func (h *HTTPSource) AuthenticationContext(ctx context.Context, ident, namespace string, version int64) (*tenant.Authentication, error) {
 	authentication := &tenant.Authentication{}
 
-	if err := h.get(fmt.Sprintf("/system/v1/authentication/%s/%s/%d", ident, namespace, version), authentication); err != nil {
+	if err := h.get(ctx, fmt.Sprintf("/system/v1/authentication/%s/%s/%d", ident, namespace, version), authentication); err != nil {
 		return nil, errors.Wrap(err, "failed to get /authentication")
 	}
 
@@ -146,46 +305,265 @@ func (h *HTTPSource) Authentication(ident, namespace string, version int64) (*te
 
 // Capabilities returns the Capabilities for the system.
 func (h *HTTPSource) Capabilities(ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
+	return h.CapabilitiesContext(context.Background(), ident, namespace, version)
+}
+
+// CapabilitiesContext returns the Capabilities for the system.
+func (h *HTTPSource) CapabilitiesContext(ctx context.Context, ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
 	caps := &capabilities.CapabilityConfig{}
 
-	if err := h.get(fmt.Sprintf("/system/v1/caps/%s/%s/%d", ident, namespace, version), caps); err != nil {
+	if err := h.get(ctx, fmt.Sprintf("/system/v1/caps/%s/%s/%d", ident, namespace, version), caps); err != nil {
 		return nil, errors.Wrap(err, "failed to get /caps")
 	}
 
 	return caps, nil
 }
 
-// pingServer loops forever until it finds a server at the configured host.
-func (h *HTTPSource) pingServer() error {
-	for {
-		if err := h.get("/system/v1/state", nil); err != nil {
-			time.Sleep(time.Second)
+// ErrWatchUnsupported is returned by Watch when the server has no watch endpoint, such as an older server.
+var ErrWatchUnsupported = errors.New("server does not support watching for changes")
+
+// Watch streams change events from the server's /system/v1/watch endpoint, reconnecting whenever the stream is
+// interrupted until ctx is cancelled. Errors that reconnecting cannot fix, such as a rejected credential or a server
+// without a watch endpoint (ErrWatchUnsupported), are returned if they occur on the first connection; if they occur
+// on a later one, the channel is closed and calling Watch again returns the error.
+func (h *HTTPSource) Watch(ctx context.Context) (<-chan system.Event, error) {
+	resp, err := h.openStream(ctx)
+	if err != nil && !errors.Is(err, system.ErrUnavailable) {
+		return nil, err
+	}
+
+	events := make(chan system.Event)
+
+	go func() {
+		defer close(events)
+
+		for {
+			if resp != nil {
+				// errors reading the stream are not fatal for the watcher; the stream is simply re-established.
+				_ = h.readEvents(ctx, resp, events)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+
+			resp, err = h.openStream(ctx)
+			if err != nil && !errors.Is(err, system.ErrUnavailable) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// openStream connects to the watch endpoint. Failures that may be fixed by reconnecting are returned as
+// system.ErrUnavailable. If a CredentialSupplier is configured, a 401 response causes the credential to be
+// refreshed and the connection to be retried once.
+func (h *HTTPSource) openStream(ctx context.Context) (*http.Response, error) {
+	for refreshed := false; ; refreshed = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/system/v1/watch", h.host), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to NewRequest")
+		}
+
+		req.Header.Set("Accept", "text/event-stream")
+
+		auth, err := h.authorization(ctx, refreshed)
+		if err != nil {
+			return nil, err
+		}
 
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		// the stream is long-lived, so the client's timeout must not apply.
+		streamClient := &http.Client{Transport: h.client.Transport}
+
+		resp, err := streamClient.Do(req)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, errors.Wrap(ctxErr, "failed to Do request")
+			}
+
+			return nil, unavailable(err, "failed to Do request")
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusUnauthorized && h.supplier != nil && !refreshed {
+			continue
+		}
+
+		return nil, statusError(resp.StatusCode, body, ErrWatchUnsupported)
+	}
+}
+
+// readEvents reads server-sent events from a watch stream and sends them to events until the stream ends.
+func (h *HTTPSource) readEvents(ctx context.Context, resp *http.Response, events chan<- system.Event) error {
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			// event names, comments, and blank lines carry nothing that isn't also in the data.
+			continue
+		}
+
+		event := system.Event{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
 			continue
 		}
 
-		break
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "failed to read event stream")
+	}
+
+	return nil
+}
+
+// pingServer loops until it finds a server at the configured host or ctx is done.
+func (h *HTTPSource) pingServer(ctx context.Context) error {
+	for {
+		if err := h.get(ctx, "/system/v1/state", nil); err == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
 	}
 
 	return nil
 }
 
 // get performs a GET request against the configured host and given path.
-func (h *HTTPSource) get(path string, dest any) error {
-	return h.authedGet(path, h.authHeader, dest)
+func (h *HTTPSource) get(ctx context.Context, path string, dest any) error {
+	return h.authedGet(ctx, path, dest, system.ErrTenantNotFound)
+}
+
+// authedGet performs a GET request against the configured host and given path with the current auth header,
+// retrying according to the configured RetryPolicy. If a CredentialSupplier is configured, a 401 response causes
+// the credential to be refreshed and the request to be retried once. A 404 response is returned as [notFound]
+// unless the server indicated a more specific error.
+func (h *HTTPSource) authedGet(ctx context.Context, path string, dest any, notFound error) error {
+	return h.withRetries(ctx, path, func(reqURL, auth string) error {
+		return h.attemptGet(ctx, reqURL, auth, dest, notFound)
+	})
 }
 
-// authedGet performs a GET request against the configured host and given path with the given auth header.
-func (h *HTTPSource) authedGet(path, auth string, dest any) error {
+// withRetries calls attempt with the URL for the given path and the current auth header until it succeeds,
+// fails with an error other than system.ErrUnavailable, or the configured RetryPolicy is exhausted.
+func (h *HTTPSource) withRetries(ctx context.Context, path string, attempt func(reqURL, auth string) error) error {
 	parsedURL, err := url.Parse(fmt.Sprintf("%s%s", h.host, path))
 	if err != nil {
 		return errors.Wrap(err, "failed to parsedURL.Parse")
 	}
 
-	ctx, cxl := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cxl()
+	refreshed := false
+
+	for n := 1; ; n++ {
+		// the credential is fetched first, so that a supplier failure can't take a half-open breaker's trial
+		// without recording its outcome.
+		auth, err := h.authorization(ctx, false)
+		if err != nil {
+			return err
+		}
+
+		if err := h.breaker.allow(); err != nil {
+			return err
+		}
+
+		err = attempt(parsedURL.String(), auth)
+
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, which says nothing about whether the server is healthy.
+			h.breaker.release()
+			return err
+		}
+
+		h.breaker.record(err)
+
+		if errors.Is(err, system.ErrAuthenticationFailed) && h.supplier != nil && !refreshed {
+			if _, err := h.authorization(ctx, true); err != nil {
+				return err
+			}
+
+			refreshed = true
+			n--
+
+			continue
+		}
+
+		if err == nil || !errors.Is(err, system.ErrUnavailable) || n >= h.retry.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(h.retry.backoff(n)):
+		}
+	}
+}
+
+// authorization returns the Authorization header to use for requests. If a CredentialSupplier is configured, the
+// credential is requested from it when there is none, when it has expired, or when [refresh] is true.
+func (h *HTTPSource) authorization(ctx context.Context, refresh bool) (string, error) {
+	if h.supplier == nil {
+		return h.authHeader, nil
+	}
+
+	h.authLock.Lock()
+	defer h.authLock.Unlock()
+
+	if !refresh && h.credential != nil && !system.CredentialExpired(h.credential) {
+		return h.authHeader, nil
+	}
+
+	creds, err := h.supplier(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get credential from supplier")
+	}
+
+	h.credential = creds
+	h.authHeader = fmt.Sprintf("%s %s", creds.Scheme(), creds.Value())
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+	return h.authHeader, nil
+}
+
+// attemptGet makes a single GET request. Transport failures, timeouts, and 5xx responses are returned as
+// system.ErrUnavailable, while the cancellation of [parent] is returned as its error.
+func (h *HTTPSource) attemptGet(parent context.Context, reqURL, auth string, dest any, notFound error) error {
+	ctx := parent
+
+	if h.client.Timeout > 0 {
+		var cxl context.CancelFunc
+
+		ctx, cxl = context.WithTimeout(parent, h.client.Timeout)
+		defer cxl()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to NewRequest")
 	}
@@ -196,21 +574,21 @@ func (h *HTTPSource) authedGet(path, auth string, dest any) error {
 
 	resp, err := h.client.Do(req)
 	if err != nil {
-		return errors.Wrap(err, "failed to Do request")
+		if parentErr := parent.Err(); parentErr != nil {
+			return errors.Wrap(parentErr, "failed to Do request")
+		}
+
+		return unavailable(err, "failed to Do request")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return errors.Wrap(err, "failed to ReadAll body")
+		return unavailable(err, "failed to ReadAll body")
 	}
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		return errors.WithMessage(system.ErrAuthenticationFailed, fmt.Sprintf("response body: %s", string(body)))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("response returned non-200 status: %d with error message: %s", resp.StatusCode, string(body))
+	if err := statusError(resp.StatusCode, body, notFound); err != nil {
+		return err
 	}
 
 	if dest != nil {
@@ -221,3 +599,148 @@ func (h *HTTPSource) authedGet(path, auth string, dest any) error {
 
 	return nil
 }
+
+// attemptStream makes a single GET request for module bytes, returning the response body without reading it.
+func (h *HTTPSource) attemptStream(parent context.Context, reqURL, auth, etag string) (*system.ModuleData, error) {
+	ctx, cxl := context.WithCancel(parent)
+
+	// the timeout only applies until the response headers arrive, so that large modules can be streamed.
+	// As with http.Client, a zero timeout means no timeout.
+	stopTimer := func() bool { return true }
+
+	if h.client.Timeout > 0 {
+		timer := time.AfterFunc(h.client.Timeout, cxl)
+		stopTimer = timer.Stop
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		cxl()
+		return nil, errors.Wrap(err, "failed to NewRequest")
+	}
+
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	streamClient := &http.Client{Transport: h.client.Transport}
+
+	resp, err := streamClient.Do(req)
+	timedOut := !stopTimer()
+
+	if err == nil && timedOut {
+		resp.Body.Close()
+		err = context.DeadlineExceeded
+	}
+
+	if err != nil {
+		cxl()
+
+		if parentErr := parent.Err(); parentErr != nil {
+			return nil, errors.Wrap(parentErr, "failed to Do request")
+		}
+
+		return nil, unavailable(err, "failed to Do request")
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		cxl()
+
+		return nil, system.ErrModuleNotModified
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer cxl()
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+		return nil, statusError(resp.StatusCode, body, system.ErrModuleNotFound)
+	}
+
+	data := &system.ModuleData{
+		ReadCloser: &cancelOnClose{ReadCloser: resp.Body, cancel: cxl},
+		ETag:       resp.Header.Get("ETag"),
+		Size:       resp.ContentLength,
+	}
+
+	return data, nil
+}
+
+// cancelOnClose cancels a request's context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+
+	return c.ReadCloser.Close()
+}
+
+// statusError returns the error described by a non-200 response status, if any.
+func statusError(status int, body []byte, notFound error) error {
+	switch {
+	case status == http.StatusUnauthorized:
+		return errors.WithMessage(system.ErrAuthenticationFailed, fmt.Sprintf("response body: %s", string(body)))
+	case status == http.StatusNotFound:
+		return errors.WithMessage(notFoundError(body, notFound), fmt.Sprintf("response body: %s", string(body)))
+	case status >= http.StatusInternalServerError:
+		return errors.WithMessage(system.ErrUnavailable, fmt.Sprintf("response returned status %d with error message: %s", status, string(body)))
+	case status != http.StatusOK:
+		return fmt.Errorf("response returned non-200 status: %d with error message: %s", status, string(body))
+	}
+
+	return nil
+}
+
+// notFoundError returns the not-found error identified by the code in the response body, otherwise fallback.
+func notFoundError(body []byte, fallback error) error {
+	resp := struct {
+		Code string `json:"code"`
+	}{}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fallback
+	}
+
+	if err := system.ErrorForCode(resp.Code); err != nil {
+		return err
+	}
+
+	return fallback
+}
+
+// unavailableError is returned for failures that make the source unavailable. It matches system.ErrUnavailable
+// with errors.Is while keeping the underlying error as its cause.
+type unavailableError struct {
+	cause error
+}
+
+// unavailable returns err annotated with message as an error that matches system.ErrUnavailable.
+func unavailable(err error, message string) error {
+	return &unavailableError{cause: errors.Wrap(err, message)}
+}
+
+func (u *unavailableError) Error() string {
+	return fmt.Sprintf("%s: %s", system.ErrUnavailable, u.cause)
+}
+
+func (u *unavailableError) Is(target error) bool {
+	return target == system.ErrUnavailable
+}
+
+func (u *unavailableError) Unwrap() error {
+	return u.cause
+}
+
+// Cause returns the underlying error, for use with errors.Cause.
+func (u *unavailableError) Cause() error {
+	return u.cause
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/suborbital/systemspec/system"
+)
+
+func TestStartContextCancellation(t *testing.T) {
+	// a server that is immediately closed gives us an address that refuses connections.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	source := NewHTTPSource(srv.URL, nil).(*HTTPSource)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := source.StartContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected StartContext to return the context's error, got:", err)
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Error("StartContext did not return promptly after the deadline")
+	}
+}
+
+func TestRetryAndTypedErrors(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/system/v1/state":
+			// fail the first two attempts.
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"systemVersion": 7}`))
+		case "/system/v1/workflows/com.suborbital.test/default/9":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"failed to find requested tenant version","code":"versionNotFound"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	source := NewHTTPSource(srv.URL, nil, WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	state, err := source.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.SystemVersion != 7 || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("expected success on third attempt, got version %d after %d calls", state.SystemVersion, calls)
+	}
+
+	if _, err := source.GetModule("fqmn://com.suborbital.test/default/hello@abc123"); !errors.Is(err, system.ErrModuleNotFound) {
+		t.Error("expected ErrModuleNotFound, got:", err)
+	}
+
+	if _, err := source.Workflows("com.suborbital.test", "default", 9); !errors.Is(err, system.ErrVersionNotFound) {
+		t.Error("expected ErrVersionNotFound, got:", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	source := NewHTTPSource(srv.URL, nil, WithCircuitBreaker(2, time.Minute))
+
+	for i := 0; i < 5; i++ {
+		if _, err := source.State(); !errors.Is(err, system.ErrUnavailable) {
+			t.Error("expected ErrUnavailable, got:", err)
+		}
+	}
+
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected the breaker to open after 2 calls, got %d", calls)
+	}
+}
+
+func TestCredentialSupplierRefresh(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected atomic.Value
+	expected.Store("Bearer first")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != expected.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"systemVersion": 1}`))
+	}))
+	defer srv.Close()
+
+	source := NewHTTPSource(srv.URL, nil, WithCredentialSupplier(system.FileCredentialSupplier("Bearer", tokenPath)))
+
+	if _, err := source.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate the token; the next request is rejected, the supplier is called again, and the request retried.
+	if err := os.WriteFile(tokenPath, []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected.Store("Bearer second")
+
+	if _, err := source.State(); err != nil {
+		t.Error("expected the credential to be refreshed after a 401, got:", err)
+	}
+
+	expected.Store("Bearer third")
+
+	if _, err := source.State(); !errors.Is(err, system.ErrAuthenticationFailed) {
+		t.Error("expected ErrAuthenticationFailed when the refreshed credential is also rejected, got:", err)
+	}
+}
+
+func TestWatchNonTransientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// an older server without a watch endpoint.
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := NewHTTPSource(srv.URL, nil).(*HTTPSource).Watch(ctx); !errors.Is(err, system.ErrAuthenticationFailed) {
+		t.Error("expected ErrAuthenticationFailed, got:", err)
+	}
+
+	if _, err := NewHTTPSource(srv.URL, system.NewCredential("Bearer", "token")).(*HTTPSource).Watch(ctx); !errors.Is(err, ErrWatchUnsupported) {
+		t.Error("expected ErrWatchUnsupported, got:", err)
+	}
+}
+
+func TestZeroTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"systemVersion": 1}`))
+	}))
+	defer srv.Close()
+
+	// a zero timeout means no timeout, as it does for http.Client.
+	if _, err := NewHTTPSource(srv.URL, nil, WithTimeout(0)).State(); err != nil {
+		t.Error("expected a zero timeout not to fail the request, got:", err)
+	}
+}
+
+func TestUnavailableKeepsCause(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	_, err := NewHTTPSource(srv.URL, nil).State()
+	if !errors.Is(err, system.ErrUnavailable) {
+		t.Fatal("expected ErrUnavailable, got:", err)
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Error("expected the transport error to be kept as the cause, got:", err)
+	}
+}
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	var healthy atomic.Bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	source := NewHTTPSource(srv.URL, nil, WithCircuitBreaker(1, time.Millisecond)).(*HTTPSource)
+
+	if _, err := source.State(); !errors.Is(err, system.ErrUnavailable) {
+		t.Fatal("expected ErrUnavailable, got:", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	healthy.Store(true)
+
+	// the trial request is abandoned by the caller, which must not close the circuit.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := source.StateContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected the trial to be cancelled, got:", err)
+	}
+
+	source.breaker.lock.Lock()
+	defer source.breaker.lock.Unlock()
+
+	if source.breaker.failures != 1 || source.breaker.trial {
+		t.Errorf("expected a cancelled trial to leave the failures and release the trial, got %d failures, trial: %t", source.breaker.failures, source.breaker.trial)
+	}
+}
+
+func TestCircuitBreakerCredentialFailure(t *testing.T) {
+	var healthy, supplierDown atomic.Bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"systemVersion": 1}`))
+	}))
+	defer srv.Close()
+
+	// the credential has already expired, so the supplier is called for every request.
+	supplier := func(context.Context) (system.Credential, error) {
+		if supplierDown.Load() {
+			return nil, errors.New("credential supplier unavailable")
+		}
+
+		return system.NewExpiringCredential("Bearer", "token", time.Now()), nil
+	}
+
+	source := NewHTTPSource(srv.URL, nil, WithCircuitBreaker(1, time.Millisecond), WithCredentialSupplier(supplier))
+
+	if _, err := source.State(); !errors.Is(err, system.ErrUnavailable) {
+		t.Fatal("expected ErrUnavailable, got:", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	// a request that fails to get a credential while the breaker is half-open must not use up its trial.
+	supplierDown.Store(true)
+
+	if _, err := source.State(); err == nil || errors.Is(err, system.ErrUnavailable) {
+		t.Fatal("expected the supplier's error, got:", err)
+	}
+
+	supplierDown.Store(false)
+	healthy.Store(true)
+
+	if state, err := source.State(); err != nil || state.SystemVersion != 1 {
+		t.Errorf("expected the breaker to close once the server recovered, got %v (%v)", state, err)
+	}
+}
+
+func TestZeroTimeoutStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("wasm"))
+	}))
+	defer srv.Close()
+
+	data, err := NewHTTPSource(srv.URL, nil, WithTimeout(0)).(*HTTPSource).ReadModule(context.Background(), "fqmn://com.suborbital.test/default/hello@abc123", "")
+	if err != nil {
+		t.Fatal("expected a zero timeout not to fail the stream, got:", err)
+	}
+
+	data.Close()
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/suborbital/systemspec/modulecache"
+	"github.com/suborbital/systemspec/system"
+)
+
+// Option configures an HTTPSource.
+type Option func(*HTTPSource)
+
+// RetryPolicy describes how failed requests are retried. Only failures that leave the server's
+// answer unknown (transport errors and 5xx responses) are retried, as all requests are idempotent GETs.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for each request, including the first.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubling for each subsequent retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries.
+	MaxDelay time.Duration
+}
+
+// WithRetry configures the HTTPSource to retry failed requests with exponential backoff and full jitter.
+func WithRetry(policy RetryPolicy) Option {
+	return func(h *HTTPSource) {
+		h.retry = policy
+	}
+}
+
+// WithCircuitBreaker configures the HTTPSource to fail fast with system.ErrUnavailable for [cooldown] once
+// [threshold] consecutive requests have failed, after which a single trial request is allowed through.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(h *HTTPSource) {
+		h.breaker = newBreaker(threshold, cooldown)
+	}
+}
+
+// WithCredentialSupplier configures the HTTPSource to get its credential from [supplier] rather than using a static one.
+// The supplier is called before the first request, whenever the credential expires (see system.ExpiringCredential),
+// and whenever the server rejects the current credential.
+func WithCredentialSupplier(supplier system.CredentialSupplier) Option {
+	return func(h *HTTPSource) {
+		h.supplier = supplier
+	}
+}
+
+// WithTimeout sets the timeout for each individual request attempt.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *HTTPSource) {
+		h.client.Timeout = timeout
+	}
+}
+
+// WithModuleCache configures GetModule to store module bytes in [cache] along with the ETag they were served with,
+// and to revalidate them against the server's binary endpoint so that unchanged modules are not downloaded again.
+func WithModuleCache(cache *modulecache.Cache) Option {
+	return func(h *HTTPSource) {
+		h.modules = cache
+	}
+}
+
+// backoff returns the delay before the given retry, using exponential backoff with full jitter.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	if r.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := r.BaseDelay << (attempt - 1)
+	if delay <= 0 || (r.MaxDelay > 0 && delay > r.MaxDelay) {
+		delay = r.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1)) //nolint:gosec // jitter doesn't need a secure source.
+}
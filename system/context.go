@@ -0,0 +1,156 @@
+package system
+
+import (
+	"context"
+	"io"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// ContextSource is the context-aware variant of Source. Each method honors the cancellation and
+// deadline of the provided context, and implementations propagate it to any requests they make.
+type ContextSource interface {
+	// StartContext indicates to the Source that it should prepare for system startup. It returns ctx.Err()
+	// if ctx is done before the Source is ready. ctx only bounds startup: any background work that the Source
+	// starts, such as watching for changes, runs until the Source is closed (see Close).
+	StartContext(ctx context.Context) error
+
+	// StateContext returns the state of the entire system, used for cache invalidation and sync purposes
+	StateContext(ctx context.Context) (*State, error)
+
+	// OverviewContext returns a the system overview, used for incremental sync of the system's tenants
+	OverviewContext(ctx context.Context) (*Overview, error)
+
+	// TenantOverviewContext returns the overview for the requested tenant
+	TenantOverviewContext(ctx context.Context, ident string) (*TenantOverview, error)
+
+	// GetModuleContext attempts to find the given module by its fqmn, and returns ErrModuleNotFound if it cannot.
+	GetModuleContext(ctx context.Context, FQMN string) (*tenant.Module, error)
+
+	// WorkflowsContext returns the requested workflows for the system.
+	WorkflowsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Workflow, error)
+
+	// ConnectionsContext returns the connections needed for the system.
+	ConnectionsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Connection, error)
+
+	// AuthenticationContext provides any auth headers or metadata for the system.
+	AuthenticationContext(ctx context.Context, ident, namespace string, version int64) (*tenant.Authentication, error)
+
+	// CapabilitiesContext provides the tenant's configured capabilities.
+	CapabilitiesContext(ctx context.Context, ident, namespace string, version int64) (*capabilities.CapabilityConfig, error)
+}
+
+// Close stops any background work started by the given Source if it implements io.Closer, otherwise does nothing.
+func Close(source Source) error {
+	if closer, ok := source.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// contextStarter is implemented by Sources that only need a context to start.
+type contextStarter interface {
+	StartContext(ctx context.Context) error
+}
+
+// WithContext returns a ContextSource for the given Source. If the Source already implements ContextSource
+// it is returned as-is, otherwise it is wrapped in an adapter that checks the context before each call.
+func WithContext(source Source) ContextSource {
+	if cs, ok := source.(ContextSource); ok {
+		return cs
+	}
+
+	return &contextAdapter{source: source}
+}
+
+// contextAdapter adapts a Source to the ContextSource interface.
+type contextAdapter struct {
+	source Source
+}
+
+// StartContext starts the Source, returning early if ctx is done first. If the Source implements
+// StartContext itself, that is used so that it can stop its own background work.
+func (c *contextAdapter) StartContext(ctx context.Context) error {
+	if starter, ok := c.source.(contextStarter); ok {
+		return starter.StartContext(ctx)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.source.Start()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *contextAdapter) StateContext(ctx context.Context) (*State, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.State()
+}
+
+func (c *contextAdapter) OverviewContext(ctx context.Context) (*Overview, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.Overview()
+}
+
+func (c *contextAdapter) TenantOverviewContext(ctx context.Context, ident string) (*TenantOverview, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.TenantOverview(ident)
+}
+
+func (c *contextAdapter) GetModuleContext(ctx context.Context, FQMN string) (*tenant.Module, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.GetModule(FQMN)
+}
+
+func (c *contextAdapter) WorkflowsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Workflow, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.Workflows(ident, namespace, version)
+}
+
+func (c *contextAdapter) ConnectionsContext(ctx context.Context, ident, namespace string, version int64) ([]tenant.Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.Connections(ident, namespace, version)
+}
+
+func (c *contextAdapter) AuthenticationContext(ctx context.Context, ident, namespace string, version int64) (*tenant.Authentication, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.Authentication(ident, namespace, version)
+}
+
+func (c *contextAdapter) CapabilitiesContext(ctx context.Context, ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.source.Capabilities(ident, namespace, version)
+}
@@ -0,0 +1,109 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ExpiringCredential is a Credential that is known to become invalid at a certain time.
+type ExpiringCredential interface {
+	Credential
+	ExpiresAt() time.Time
+}
+
+// tokenCredential is a static Credential with an optional expiry.
+type tokenCredential struct {
+	scheme    string
+	value     string
+	expiresAt time.Time
+}
+
+// NewCredential returns a Credential with the given scheme (such as "Bearer") and value.
+func NewCredential(scheme, value string) Credential {
+	return &tokenCredential{scheme: scheme, value: value}
+}
+
+// NewExpiringCredential returns a Credential with the given scheme and value that expires at the given time.
+func NewExpiringCredential(scheme, value string, expiresAt time.Time) ExpiringCredential {
+	return &tokenCredential{scheme: scheme, value: value, expiresAt: expiresAt}
+}
+
+func (t *tokenCredential) Scheme() string {
+	return t.scheme
+}
+
+func (t *tokenCredential) Value() string {
+	return t.value
+}
+
+func (t *tokenCredential) ExpiresAt() time.Time {
+	return t.expiresAt
+}
+
+// CredentialExpired returns true if the credential is an ExpiringCredential whose expiry has passed.
+func CredentialExpired(creds Credential) bool {
+	expiring, ok := creds.(ExpiringCredential)
+	if !ok || expiring.ExpiresAt().IsZero() {
+		return false
+	}
+
+	return !time.Now().Before(expiring.ExpiresAt())
+}
+
+// FileCredentialSupplier returns a CredentialSupplier that reads the token from the file at [path] each time it is called.
+func FileCredentialSupplier(scheme, path string) CredentialSupplier {
+	return func(_ context.Context) (Credential, error) {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to ReadFile")
+		}
+
+		return tokenFromOutput(scheme, contents)
+	}
+}
+
+// EnvCredentialSupplier returns a CredentialSupplier that reads the token from the environment variable [name] each time it is called.
+func EnvCredentialSupplier(scheme, name string) CredentialSupplier {
+	return func(_ context.Context) (Credential, error) {
+		val, exists := os.LookupEnv(name)
+		if !exists {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return tokenFromOutput(scheme, []byte(val))
+	}
+}
+
+// ExecCredentialSupplier returns a CredentialSupplier that runs the given command each time it is called,
+// using its standard output as the token.
+func ExecCredentialSupplier(scheme, name string, args ...string) CredentialSupplier {
+	return func(ctx context.Context) (Credential, error) {
+		stdout := &bytes.Buffer{}
+
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = stdout
+
+		if err := cmd.Run(); err != nil {
+			return nil, errors.Wrapf(err, "failed to run %s", name)
+		}
+
+		return tokenFromOutput(scheme, stdout.Bytes())
+	}
+}
+
+// tokenFromOutput creates a Credential from the given output, ignoring surrounding whitespace.
+func tokenFromOutput(scheme string, output []byte) (Credential, error) {
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return nil, errors.Wrap(ErrAuthenticationFailed, "supplied credential is empty")
+	}
+
+	return NewCredential(scheme, token), nil
+}
@@ -0,0 +1,66 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialSuppliers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SYSTEMSPEC_TEST_TOKEN", " from-env ")
+
+	suppliers := map[string]struct {
+		supplier CredentialSupplier
+		expected string
+	}{
+		"file": {supplier: FileCredentialSupplier("Bearer", path), expected: "from-file"},
+		"env":  {supplier: EnvCredentialSupplier("Bearer", "SYSTEMSPEC_TEST_TOKEN"), expected: "from-env"},
+		"exec": {supplier: ExecCredentialSupplier("Bearer", "echo", "from-exec"), expected: "from-exec"},
+	}
+
+	for name, tc := range suppliers {
+		creds, err := tc.supplier(context.Background())
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+
+		if creds.Scheme() != "Bearer" || creds.Value() != tc.expected {
+			t.Errorf("%s: expected Bearer %s, got %s %s", name, tc.expected, creds.Scheme(), creds.Value())
+		}
+	}
+
+	t.Setenv("SYSTEMSPEC_TEST_EMPTY", "  ")
+
+	failing := map[string]CredentialSupplier{
+		"missing file":  FileCredentialSupplier("Bearer", filepath.Join(t.TempDir(), "missing")),
+		"unset env":     EnvCredentialSupplier("Bearer", "SYSTEMSPEC_TEST_UNSET"),
+		"failed exec":   ExecCredentialSupplier("Bearer", "false"),
+		"empty env":     EnvCredentialSupplier("Bearer", "SYSTEMSPEC_TEST_EMPTY"),
+		"empty command": ExecCredentialSupplier("Bearer", "true"),
+	}
+
+	for name, supplier := range failing {
+		if _, err := supplier(context.Background()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := failing["empty env"](context.Background()); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Error("expected an empty credential to be ErrAuthenticationFailed, got:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ExecCredentialSupplier("Bearer", "sleep", "10")(ctx); err == nil {
+		t.Error("expected a cancelled command to fail")
+	}
+}
@@ -0,0 +1,146 @@
+package system
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DefaultDeltaHistory is the number of overview snapshots kept by a DeltaTracker that doesn't configure its own.
+const DefaultDeltaHistory = 64
+
+// OverviewDelta describes the changes to the system's tenants since a previous system version.
+type OverviewDelta struct {
+	State
+
+	// Since is the system version that the delta is relative to.
+	Since int64 `json:"since"`
+
+	// Full is true if the delta could not be computed relative to Since, in which case Added contains every
+	// tenant in the system and the receiver should remove any tenant not listed.
+	Full bool `json:"full"`
+
+	// Added, Changed, and Removed map tenant idents to their latest tenant version.
+	Added   map[string]int64 `json:"added"`
+	Changed map[string]int64 `json:"changed"`
+	Removed []string         `json:"removed"`
+}
+
+// Empty returns true if the delta contains no changes.
+func (o *OverviewDelta) Empty() bool {
+	return !o.Full && len(o.Added) == 0 && len(o.Changed) == 0 && len(o.Removed) == 0
+}
+
+// DeltaSource is a Source that can describe the changes to its tenants since a previous system version,
+// avoiding the need to transfer the full Overview on each sync.
+type DeltaSource interface {
+	Source
+
+	// OverviewSince returns the changes to the system's tenants since the given system version.
+	// A since value of 0 always returns a Full delta.
+	OverviewSince(since int64) (*OverviewDelta, error)
+}
+
+// DeltaContextSource is the context-aware variant of DeltaSource.
+type DeltaContextSource interface {
+	// OverviewSinceContext is OverviewSince with a context that bounds the request.
+	OverviewSinceContext(ctx context.Context, since int64) (*OverviewDelta, error)
+}
+
+// DiffIdentifiers computes the delta between two sets of tenant references.
+func DiffIdentifiers(since int64, state State, previous, current map[string]int64) *OverviewDelta {
+	delta := &OverviewDelta{
+		State:   state,
+		Since:   since,
+		Added:   map[string]int64{},
+		Changed: map[string]int64{},
+		Removed: []string{},
+	}
+
+	for ident, version := range current {
+		prevVersion, exists := previous[ident]
+		if !exists {
+			delta.Added[ident] = version
+		} else if prevVersion != version {
+			delta.Changed[ident] = version
+		}
+	}
+
+	for ident := range previous {
+		if _, exists := current[ident]; !exists {
+			delta.Removed = append(delta.Removed, ident)
+		}
+	}
+
+	sort.Strings(delta.Removed)
+
+	return delta
+}
+
+// FullDelta returns a delta containing every tenant in the given overview.
+func FullDelta(since int64, ovv *Overview) *OverviewDelta {
+	delta := DiffIdentifiers(since, ovv.State, nil, ovv.TenantRefs.Identifiers)
+	delta.Full = true
+
+	return delta
+}
+
+// DeltaTracker records snapshots of a Source's Overview by system version so that
+// deltas can be computed for Sources that don't implement DeltaSource themselves.
+type DeltaTracker struct {
+	history   int
+	snapshots map[int64]map[string]int64
+	versions  []int64
+
+	lock sync.Mutex
+}
+
+// NewDeltaTracker creates a DeltaTracker that keeps up to [history] snapshots.
+func NewDeltaTracker(history int) *DeltaTracker {
+	d := &DeltaTracker{
+		history:   history,
+		snapshots: map[int64]map[string]int64{},
+		lock:      sync.Mutex{},
+	}
+
+	return d
+}
+
+// Record stores a snapshot of the given overview, evicting the oldest snapshot if needed.
+func (d *DeltaTracker) Record(ovv *Overview) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, exists := d.snapshots[ovv.SystemVersion]; exists {
+		return
+	}
+
+	snapshot := make(map[string]int64, len(ovv.TenantRefs.Identifiers))
+	for ident, version := range ovv.TenantRefs.Identifiers {
+		snapshot[ident] = version
+	}
+
+	d.snapshots[ovv.SystemVersion] = snapshot
+	d.versions = append(d.versions, ovv.SystemVersion)
+
+	if len(d.versions) > d.history {
+		delete(d.snapshots, d.versions[0])
+		d.versions = d.versions[1:]
+	}
+}
+
+// Since records the current overview and returns the delta from the snapshot recorded for
+// the given system version, or a Full delta if there is no such snapshot.
+func (d *DeltaTracker) Since(since int64, current *Overview) *OverviewDelta {
+	d.Record(current)
+
+	d.lock.Lock()
+	previous, exists := d.snapshots[since]
+	d.lock.Unlock()
+
+	if since == 0 || !exists {
+		return FullDelta(since, current)
+	}
+
+	return DiffIdentifiers(since, current.State, previous, current.TenantRefs.Identifiers)
+}
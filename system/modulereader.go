@@ -0,0 +1,81 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/fqmn"
+)
+
+// ModuleReader is implemented by Sources that can stream a module's Wasm bytes
+// rather than embedding them in the Module returned by GetModule.
+type ModuleReader interface {
+	// ReadModule returns the Wasm bytes of the module with the given FQMN, which the caller must Close.
+	// If etag is not empty and matches the module's current ETag, ErrModuleNotModified is returned instead.
+	ReadModule(ctx context.Context, FQMN, etag string) (*ModuleData, error)
+}
+
+// ModuleData is a stream of a module's Wasm bytes.
+type ModuleData struct {
+	io.ReadCloser
+
+	// ETag identifies the contents of the module, see ModuleETag.
+	ETag string
+
+	// Size is the number of bytes in the stream, or -1 if it is not known.
+	Size int64
+}
+
+// ModuleETag returns the (quoted) ETag for a module with the given ref in the given system version. The system
+// version is included because a source may serve different bytes under the same ref once it is reloaded.
+func ModuleETag(ref string, systemVersion int64) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%s-%d", ref, systemVersion))
+}
+
+// ReadModule returns the Wasm bytes of the given module from the source, using its ReadModule
+// method if it implements ModuleReader, otherwise the data embedded in the result of GetModule.
+func ReadModule(ctx context.Context, source Source, FQMN, etag string) (*ModuleData, error) {
+	if reader, ok := source.(ModuleReader); ok {
+		return reader.ReadModule(ctx, FQMN, etag)
+	}
+
+	f, err := fqmn.Parse(FQMN)
+	if err != nil {
+		return nil, errors.Wrapf(ErrModuleNotFound, "failed to Parse FQMN: %s", err)
+	}
+
+	cs := WithContext(source)
+
+	// the state is read before the module, so that the ETag never claims a newer version than the bytes are from.
+	state, err := cs.StateContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to State")
+	}
+
+	moduleETag := ModuleETag(f.Ref, state.SystemVersion)
+
+	if etag != "" && etag == moduleETag {
+		return nil, ErrModuleNotModified
+	}
+
+	module, err := cs.GetModuleContext(ctx, FQMN)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to GetModule")
+	}
+
+	if module.WasmRef == nil || module.WasmRef.Data == nil {
+		return nil, errors.Wrapf(ErrModuleNotFound, "module %s has no data", FQMN)
+	}
+
+	data := &ModuleData{
+		ReadCloser: io.NopCloser(bytes.NewReader(module.WasmRef.Data)),
+		ETag:       moduleETag,
+		Size:       int64(len(module.WasmRef.Data)),
+	}
+
+	return data, nil
+}
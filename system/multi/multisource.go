@@ -0,0 +1,331 @@
+package multi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// Backend is a Source that a MultiSource routes tenants to.
+type Backend struct {
+	Source system.Source
+
+	// Tenants, if not empty, are the only tenants routed to this backend, and those tenants are
+	// never routed to any other backend. If empty, the backend may serve any other tenant.
+	Tenants []string
+}
+
+// MultiSource is a Source that combines several backends. Each tenant is routed to the backend that
+// lists it, otherwise to the highest priority unrestricted backend that has it. Once Overview has been
+// called, each tenant is pinned to the backend that Overview chose for it, so that a request for a version
+// or namespace that backend doesn't have is answered by it rather than by a lower priority backend.
+type MultiSource struct {
+	backends []Backend
+
+	// owners maps each tenant listed by a backend to that backend's index.
+	owners map[string]int
+
+	// routes maps each tenant in the last Overview to the index of the backend it was routed to.
+	routes map[string]int
+
+	// backendTenants holds the tenants in each backend's last overview, used while that backend is failing.
+	backendTenants []map[string]int64
+
+	// systemVersion is incremented whenever any backend's system version changes.
+	systemVersion  int64
+	backendVersion []int64
+
+	lock sync.Mutex
+}
+
+// NewMultiSource creates a MultiSource routing to the given backends, highest priority first.
+func NewMultiSource(backends ...Backend) system.Source {
+	m := &MultiSource{
+		backends:       backends,
+		owners:         map[string]int{},
+		routes:         map[string]int{},
+		backendTenants: make([]map[string]int64, len(backends)),
+		backendVersion: make([]int64, len(backends)),
+		lock:           sync.Mutex{},
+	}
+
+	for i, b := range backends {
+		for _, ident := range b.Tenants {
+			if _, exists := m.owners[ident]; !exists {
+				m.owners[ident] = i
+			}
+		}
+	}
+
+	return m
+}
+
+// Start starts every backend.
+func (m *MultiSource) Start() error {
+	return m.StartContext(context.Background())
+}
+
+// StartContext starts every backend with ctx.
+func (m *MultiSource) StartContext(ctx context.Context) error {
+	for i, b := range m.backends {
+		if err := system.WithContext(b.Source).StartContext(ctx); err != nil {
+			return errors.Wrapf(err, "failed to StartContext for backend %d", i)
+		}
+	}
+
+	return nil
+}
+
+// Close closes every backend, returning the first error.
+func (m *MultiSource) Close() error {
+	var firstErr error
+
+	for i, b := range m.backends {
+		if err := system.Close(b.Source); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to Close backend %d", i)
+		}
+	}
+
+	return firstErr
+}
+
+// State returns the combined state of the backends, whose system version changes whenever any backend's does.
+// A backend that fails is treated as unchanged, and an error is only returned if every backend fails.
+func (m *MultiSource) State() (*system.State, error) {
+	versions := make([]int64, len(m.backends))
+
+	var firstErr error
+
+	failed := 0
+
+	for i, b := range m.backends {
+		state, err := b.Source.State()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to State for backend %d", i)
+			}
+
+			failed++
+			versions[i] = m.lastVersion(i)
+
+			continue
+		}
+
+		versions[i] = state.SystemVersion
+	}
+
+	if failed == len(m.backends) {
+		return nil, firstErr
+	}
+
+	return &system.State{SystemVersion: m.combinedVersion(versions)}, nil
+}
+
+// Overview merges the overviews of the backends, including each tenant from the backend it is routed to, and pins
+// each tenant to that backend. A backend that fails is represented by its last successful overview (if any), so
+// that its tenants aren't rerouted to another backend, and an error is only returned if every backend fails.
+func (m *MultiSource) Overview() (*system.Overview, error) {
+	versions := make([]int64, len(m.backends))
+	identifiers := map[string]int64{}
+	routes := map[string]int{}
+
+	var firstErr error
+
+	failed := 0
+
+	for i, b := range m.backends {
+		var tenants map[string]int64
+
+		ovv, err := b.Source.Overview()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to Overview for backend %d", i)
+			}
+
+			failed++
+			versions[i] = m.lastVersion(i)
+
+			m.lock.Lock()
+			tenants = m.backendTenants[i]
+			m.lock.Unlock()
+		} else {
+			versions[i] = ovv.SystemVersion
+			tenants = ovv.TenantRefs.Identifiers
+
+			m.lock.Lock()
+			m.backendTenants[i] = tenants
+			m.lock.Unlock()
+		}
+
+		for ident, version := range tenants {
+			if _, exists := identifiers[ident]; exists {
+				// a higher priority backend already has this tenant.
+				continue
+			}
+
+			if !m.routable(ident, i) {
+				continue
+			}
+
+			identifiers[ident] = version
+			routes[ident] = i
+		}
+	}
+
+	if failed == len(m.backends) {
+		return nil, firstErr
+	}
+
+	m.lock.Lock()
+	m.routes = routes
+	m.lock.Unlock()
+
+	ovv := &system.Overview{
+		State:      system.State{SystemVersion: m.combinedVersion(versions)},
+		TenantRefs: system.References{Identifiers: identifiers},
+	}
+
+	return ovv, nil
+}
+
+// TenantOverview returns the overview for the requested tenant from the backend it is routed to.
+func (m *MultiSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	return route(m, ident, func(s system.Source) (*system.TenantOverview, error) {
+		return s.TenantOverview(ident)
+	})
+}
+
+// GetModule returns the module from the backend that its tenant is routed to.
+func (m *MultiSource) GetModule(FQMN string) (*tenant.Module, error) {
+	f, err := fqmn.Parse(FQMN)
+	if err != nil {
+		return nil, system.ErrModuleNotFound
+	}
+
+	return route(m, f.Tenant, func(s system.Source) (*tenant.Module, error) {
+		return s.GetModule(FQMN)
+	})
+}
+
+// Workflows returns the requested workflows from the backend the tenant is routed to.
+func (m *MultiSource) Workflows(ident, namespace string, version int64) ([]tenant.Workflow, error) {
+	return route(m, ident, func(s system.Source) ([]tenant.Workflow, error) {
+		return s.Workflows(ident, namespace, version)
+	})
+}
+
+// Connections returns the requested connections from the backend the tenant is routed to.
+func (m *MultiSource) Connections(ident, namespace string, version int64) ([]tenant.Connection, error) {
+	return route(m, ident, func(s system.Source) ([]tenant.Connection, error) {
+		return s.Connections(ident, namespace, version)
+	})
+}
+
+// Authentication returns the requested authentication from the backend the tenant is routed to.
+func (m *MultiSource) Authentication(ident, namespace string, version int64) (*tenant.Authentication, error) {
+	return route(m, ident, func(s system.Source) (*tenant.Authentication, error) {
+		return s.Authentication(ident, namespace, version)
+	})
+}
+
+// Capabilities returns the requested capabilities from the backend the tenant is routed to.
+func (m *MultiSource) Capabilities(ident, namespace string, version int64) (*capabilities.CapabilityConfig, error) {
+	return route(m, ident, func(s system.Source) (*capabilities.CapabilityConfig, error) {
+		return s.Capabilities(ident, namespace, version)
+	})
+}
+
+// routable returns true if the given tenant may be routed to the backend at the given index.
+func (m *MultiSource) routable(ident string, backend int) bool {
+	if owner, exists := m.owners[ident]; exists {
+		return owner == backend
+	}
+
+	return len(m.backends[backend].Tenants) == 0
+}
+
+// candidates returns the backends that the given tenant may be routed to, in priority order.
+func (m *MultiSource) candidates(ident string) []system.Source {
+	sources := []system.Source{}
+
+	for i, b := range m.backends {
+		if m.routable(ident, i) {
+			sources = append(sources, b.Source)
+		}
+	}
+
+	return sources
+}
+
+// lastVersion returns the system version last recorded for the backend at the given index.
+func (m *MultiSource) lastVersion(backend int) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.backendVersion[backend]
+}
+
+// pinned returns the index of the backend that the last Overview routed the given tenant to, if any.
+func (m *MultiSource) pinned(ident string) (int, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	backend, exists := m.routes[ident]
+
+	return backend, exists
+}
+
+// combinedVersion records the backends' latest system versions and returns the combined system version.
+func (m *MultiSource) combinedVersion(versions []int64) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	changed := m.systemVersion == 0
+
+	for i, version := range versions {
+		if m.backendVersion[i] != version {
+			m.backendVersion[i] = version
+			changed = true
+		}
+	}
+
+	if changed {
+		m.systemVersion++
+	}
+
+	return m.systemVersion
+}
+
+// route calls fn with the backend that the given tenant is pinned to. If it isn't pinned, fn is called with each
+// candidate backend in turn until one returns something other than ErrTenantNotFound, as only that error means the
+// backend doesn't have the tenant at all; any other error, such as ErrVersionNotFound, is the tenant's answer.
+func route[T any](m *MultiSource, ident string, fn func(s system.Source) (T, error)) (T, error) {
+	if backend, exists := m.pinned(ident); exists {
+		return fn(m.backends[backend].Source)
+	}
+
+	var zero T
+
+	err := error(system.ErrTenantNotFound)
+
+	for _, s := range m.candidates(ident) {
+		var result T
+
+		result, err = fn(s)
+		if err == nil {
+			return result, nil
+		}
+
+		if !errors.Is(err, system.ErrTenantNotFound) {
+			return zero, err
+		}
+	}
+
+	return zero, err
+}
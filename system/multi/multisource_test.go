@@ -0,0 +1,270 @@
+package multi
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/suborbital/systemspec/bundle"
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/system"
+	bundlesource "github.com/suborbital/systemspec/system/bundle"
+	"github.com/suborbital/systemspec/system/client"
+	"github.com/suborbital/systemspec/system/server"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+var errUnavailable = errors.New("backend unavailable")
+
+// mapSource serves a fixed set of tenants, naming each workflow after itself so that the test can tell sources apart.
+type mapSource struct {
+	name    string
+	version int64
+	tenants map[string]int64
+	down    bool
+}
+
+func (m *mapSource) Start() error { return nil }
+
+func (m *mapSource) State() (*system.State, error) {
+	if m.down {
+		return nil, errUnavailable
+	}
+
+	return &system.State{SystemVersion: m.version}, nil
+}
+
+func (m *mapSource) Overview() (*system.Overview, error) {
+	if m.down {
+		return nil, errUnavailable
+	}
+
+	ovv := &system.Overview{
+		State:      system.State{SystemVersion: m.version},
+		TenantRefs: system.References{Identifiers: m.tenants},
+	}
+
+	return ovv, nil
+}
+
+func (m *mapSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	version, exists := m.tenants[ident]
+	if !exists {
+		return nil, system.ErrTenantNotFound
+	}
+
+	return &system.TenantOverview{Identifier: ident, Name: m.name, Version: version}, nil
+}
+
+func (m *mapSource) GetModule(string) (*tenant.Module, error) { return nil, system.ErrModuleNotFound }
+
+func (m *mapSource) Workflows(ident, _ string, version int64) ([]tenant.Workflow, error) {
+	if m.down {
+		return nil, errUnavailable
+	}
+
+	current, exists := m.tenants[ident]
+	if !exists {
+		return nil, system.ErrTenantNotFound
+	}
+
+	if version != system.LatestVersion && version != current {
+		return nil, system.ErrVersionNotFound
+	}
+
+	return []tenant.Workflow{{Name: m.name}}, nil
+}
+
+func (m *mapSource) Connections(string, string, int64) ([]tenant.Connection, error) {
+	return nil, system.ErrTenantNotFound
+}
+
+func (m *mapSource) Authentication(string, string, int64) (*tenant.Authentication, error) {
+	return nil, system.ErrTenantNotFound
+}
+
+// Capabilities disables HTTP, so that the test can tell its capabilities apart from the defaults.
+func (m *mapSource) Capabilities(ident, _ string, _ int64) (*capabilities.CapabilityConfig, error) {
+	if _, exists := m.tenants[ident]; !exists {
+		return nil, system.ErrTenantNotFound
+	}
+
+	return &capabilities.CapabilityConfig{HTTP: &capabilities.HTTPConfig{Enabled: false}}, nil
+}
+
+func TestMultiSourceRouting(t *testing.T) {
+	local := &mapSource{name: "local", version: 1, tenants: map[string]int64{"pinned": 1, "shared": 1}}
+	primary := &mapSource{name: "primary", version: 5, tenants: map[string]int64{"pinned": 7, "shared": 2, "remote": 3}}
+	fallback := &mapSource{name: "fallback", version: 9, tenants: map[string]int64{"extra": 1, "remote": 4}}
+
+	source := NewMultiSource(
+		Backend{Source: local, Tenants: []string{"pinned"}},
+		Backend{Source: primary},
+		Backend{Source: fallback},
+	)
+
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ovv, err := source.Overview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int64{"pinned": 1, "shared": 2, "remote": 3, "extra": 1}
+	for ident, version := range expected {
+		if ovv.TenantRefs.Identifiers[ident] != version {
+			t.Errorf("expected %s at version %d, got %d", ident, version, ovv.TenantRefs.Identifiers[ident])
+		}
+	}
+
+	if len(ovv.TenantRefs.Identifiers) != len(expected) {
+		t.Errorf("unexpected tenants: %v", ovv.TenantRefs.Identifiers)
+	}
+
+	for ident, name := range map[string]string{"pinned": "local", "shared": "primary", "remote": "primary", "extra": "fallback"} {
+		workflows, err := source.Workflows(ident, "default", expected[ident])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if workflows[0].Name != name {
+			t.Errorf("expected %s to be routed to %s, got %s", ident, name, workflows[0].Name)
+		}
+	}
+
+	// the version only exists in a lower priority backend, but remote is pinned to primary.
+	if _, err := source.Workflows("remote", "default", 4); !errors.Is(err, system.ErrVersionNotFound) {
+		t.Error("expected ErrVersionNotFound from the pinned backend, got:", err)
+	}
+
+	if _, err := source.TenantOverview("missing"); !errors.Is(err, system.ErrTenantNotFound) {
+		t.Error("expected ErrTenantNotFound for an unknown tenant, got:", err)
+	}
+
+	state, err := source.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.SystemVersion != ovv.SystemVersion {
+		t.Errorf("expected the system version to be unchanged, got %d then %d", ovv.SystemVersion, state.SystemVersion)
+	}
+
+	fallback.version++
+
+	if changed, _ := source.State(); changed.SystemVersion <= state.SystemVersion {
+		t.Errorf("expected the system version to increase when a backend changes, got %d then %d", state.SystemVersion, changed.SystemVersion)
+	}
+}
+
+func TestMultiSourceFailingBackend(t *testing.T) {
+	primary := &mapSource{name: "primary", version: 5, tenants: map[string]int64{"shared": 2}}
+	fallback := &mapSource{name: "fallback", version: 9, tenants: map[string]int64{"shared": 1, "extra": 1}}
+
+	source := NewMultiSource(Backend{Source: primary}, Backend{Source: fallback})
+
+	ovv, err := source.Overview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// while primary is down, its tenants stay routed to it rather than moving to fallback.
+	primary.down = true
+
+	failing, err := source.Overview()
+	if err != nil {
+		t.Fatal("expected Overview to tolerate a failing backend, got:", err)
+	}
+
+	if failing.SystemVersion != ovv.SystemVersion || failing.TenantRefs.Identifiers["shared"] != 2 || failing.TenantRefs.Identifiers["extra"] != 1 {
+		t.Errorf("expected the overview to be unchanged, got %+v", failing)
+	}
+
+	if _, err := source.Workflows("shared", "default", 2); !errors.Is(err, errUnavailable) {
+		t.Error("expected the pinned backend's error, got:", err)
+	}
+
+	if workflows, err := source.Workflows("extra", "default", 1); err != nil || workflows[0].Name != "fallback" {
+		t.Errorf("expected extra to be served by fallback, got %v (%v)", workflows, err)
+	}
+
+	if state, err := source.State(); err != nil || state.SystemVersion != ovv.SystemVersion {
+		t.Errorf("expected State to tolerate a failing backend, got %v (%v)", state, err)
+	}
+
+	fallback.down = true
+
+	if _, err := source.Overview(); !errors.Is(err, errUnavailable) {
+		t.Error("expected Overview to fail once every backend fails, got:", err)
+	}
+}
+
+func TestMultiSourceBundleBeforeHTTP(t *testing.T) {
+	conf := tenant.Config{
+		Identifier:    "com.suborbital.local",
+		TenantVersion: 1,
+		Modules:       []tenant.Module{{Name: "hello", Namespace: "default", Ref: "abc123"}},
+	}
+
+	confBytes, err := conf.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wasmPath := filepath.Join(t.TempDir(), "hello.wasm")
+	if err := os.WriteFile(wasmPath, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wasmFile, err := os.Open(wasmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer wasmFile.Close()
+
+	bundlePath := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+	if err := bundle.Write(confBytes, []os.File{*wasmFile}, nil, bundlePath); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &mapSource{name: "remote", version: 1, tenants: map[string]int64{"com.suborbital.remote": 2}}
+
+	srv := httptest.NewServer(server.New(remote, system.NewCredential("Bearer", "token")))
+	defer srv.Close()
+
+	source := NewMultiSource(
+		Backend{Source: bundlesource.NewBundleSource(bundlePath)},
+		Backend{Source: client.NewHTTPSource(srv.URL, system.NewCredential("Bearer", "token"))},
+	)
+
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// before any Overview pins the tenant, the bundle backend must not answer for a tenant it doesn't have.
+	for _, pin := range []bool{false, true} {
+		if pin {
+			if _, err := source.Overview(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		caps, err := source.Capabilities("com.suborbital.remote", "default", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if caps.HTTP == nil || caps.HTTP.Enabled {
+			t.Errorf("expected the remote tenant's capabilities from the HTTP backend (pinned: %t), got %+v", pin, caps.HTTP)
+		}
+
+		if _, err := source.Capabilities("com.suborbital.local", "default", 1); err != nil {
+			t.Error("expected the local tenant's capabilities from the bundle backend, got:", err)
+		}
+	}
+}
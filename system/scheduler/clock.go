@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// Clock tells the Scheduler the time, and lets it wait. It can be replaced to control time in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once [d] has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is a Clock that uses the system time.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d).
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,473 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/request"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// MethodSchedule is the Method of the requests created for scheduled runs.
+const MethodSchedule = "SCHED"
+
+// Executor runs a scheduled workflow. It is given a new request for each run, with State seeded from the
+// workflow's Schedule.State. The context is cancelled when the Scheduler stops.
+type Executor func(ctx context.Context, job Job, req *request.CoordinatedRequest) error
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithClock sets the Clock used to decide when jobs are due, which defaults to RealClock.
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithJitter sets the function that picks the random delay added to each run, given the schedule's maximum jitter.
+func WithJitter(fn func(max time.Duration) time.Duration) Option {
+	return func(s *Scheduler) {
+		s.jitter = fn
+	}
+}
+
+// OnError sets a callback that is called when a reconcile started by Run fails, or when a run's executor returns an error.
+func OnError(fn func(err error)) Option {
+	return func(s *Scheduler) {
+		s.onError = fn
+	}
+}
+
+// Job is a workflow with a schedule.
+type Job struct {
+	Tenant        string          `json:"tenant"`
+	Namespace     string          `json:"namespace"`
+	TenantVersion int64           `json:"tenantVersion"`
+	Workflow      tenant.Workflow `json:"workflow"`
+
+	// Next is when the job is next due, including jitter. It is the zero time if the schedule has ended.
+	Next time.Time `json:"next"`
+
+	// Due is when the job is next due, without jitter. The following run is scheduled from it rather than from
+	// when the run started, so that jitter and late wake-ups don't make an interval schedule drift.
+	Due time.Time `json:"due"`
+}
+
+// Key returns the job's tenant, namespace, and workflow name, which identify it within a Scheduler.
+func (j Job) Key() string {
+	return fmt.Sprintf("%s/%s/%s", j.Tenant, j.Namespace, j.Workflow.Name)
+}
+
+// runState is the state of a job that is running. It is kept by the job's key rather than with the job, so that
+// it carries over if the job is replaced, such as when its tenant is removed and re-added while it runs.
+type runState struct {
+	// queued is true if a run of a job with OverlapQueue became due while it was running.
+	queued bool
+}
+
+// Scheduler runs the workflows with a Schedule from a Source, keeping its jobs in sync as tenants change.
+type Scheduler struct {
+	source   system.Source
+	executor Executor
+	clock    Clock
+	jitter   func(max time.Duration) time.Duration
+	onError  func(err error)
+
+	jobs     map[string]*Job
+	running  map[string]*runState
+	versions map[string]int64
+	lock     sync.Mutex
+
+	// reconcileLock ensures that only one reconcile runs at a time.
+	reconcileLock sync.Mutex
+
+	runs sync.WaitGroup
+}
+
+// NewScheduler creates a Scheduler that runs the scheduled workflows from [source] using [executor].
+func NewScheduler(source system.Source, executor Executor, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		source:   source,
+		executor: executor,
+		clock:    RealClock{},
+		jobs:     map[string]*Job{},
+		running:  map[string]*runState{},
+		versions: map[string]int64{},
+		lock:     sync.Mutex{},
+	}
+
+	random := mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+
+	// the default jitter is only called with lock held, so the unsynchronized random source is safe.
+	s.jitter = func(max time.Duration) time.Duration {
+		return time.Duration(random.Int63n(int64(max) + 1))
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Jobs returns the scheduler's current jobs, ordered by Key.
+func (s *Scheduler) Jobs() []Job {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Key() < jobs[b].Key() })
+
+	return jobs
+}
+
+// Run reconciles the scheduler's jobs with the source every [interval], and runs each job when it is due,
+// until ctx is cancelled. It then waits for any runs in progress to return, and returns ctx.Err().
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("reconcile interval must be positive, got %s", interval)
+	}
+
+	defer s.runs.Wait()
+
+	nextReconcile := s.clock.Now()
+
+	for {
+		now := s.clock.Now()
+
+		if !now.Before(nextReconcile) {
+			if err := s.ReconcileContext(ctx); err != nil && ctx.Err() == nil {
+				s.reportError(err)
+			}
+
+			nextReconcile = now.Add(interval)
+		}
+
+		wait := nextReconcile.Sub(now)
+
+		if due, ok := s.runDue(ctx, now); ok && due.Sub(now) < wait {
+			wait = due.Sub(now)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(wait):
+		}
+	}
+}
+
+// Reconcile updates the scheduler's jobs to match the source. Only tenants whose version has changed are fetched.
+func (s *Scheduler) Reconcile() error {
+	return s.ReconcileContext(context.Background())
+}
+
+// ReconcileContext is Reconcile with a context that bounds every request made to the source. A tenant that fails to
+// be fetched keeps its existing jobs and is retried by the next reconcile, and the first such error is returned.
+func (s *Scheduler) ReconcileContext(ctx context.Context) error {
+	s.reconcileLock.Lock()
+	defer s.reconcileLock.Unlock()
+
+	source := system.WithContext(s.source)
+
+	ovv, err := source.OverviewContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to Overview")
+	}
+
+	var firstErr error
+
+	fetched := map[string][]Job{}
+
+	for ident, version := range ovv.TenantRefs.Identifiers {
+		if current, exists := s.tenantVersion(ident); exists && current == version {
+			continue
+		}
+
+		jobs, err := fetchJobs(ctx, source, ident)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to fetch jobs for tenant %s", ident)
+			}
+
+			continue
+		}
+
+		fetched[ident] = jobs
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for ident := range s.versions {
+		if _, exists := ovv.TenantRefs.Identifiers[ident]; !exists {
+			s.replaceJobs(ident, nil)
+			delete(s.versions, ident)
+		}
+	}
+
+	now := s.clock.Now()
+
+	for ident, jobs := range fetched {
+		for i := range jobs {
+			if err := s.schedule(&jobs[i], now); err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to schedule %s", jobs[i].Key())
+				}
+
+				// the job stays registered, but never runs until its tenant is fixed.
+				jobs[i].Next, jobs[i].Due = time.Time{}, time.Time{}
+			}
+		}
+
+		s.replaceJobs(ident, jobs)
+		s.versions[ident] = ovv.TenantRefs.Identifiers[ident]
+	}
+
+	return firstErr
+}
+
+// fetchJobs returns a Job for every workflow with a schedule in the given tenant's namespaces.
+func fetchJobs(ctx context.Context, source system.ContextSource, ident string) ([]Job, error) {
+	ovv, err := source.TenantOverviewContext(ctx, ident)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to TenantOverview")
+	}
+
+	namespaces := []string{fqmn.NamespaceDefault}
+
+	if ovv.Config != nil {
+		for _, ns := range ovv.Config.Namespaces {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	jobs := []Job{}
+
+	for _, namespace := range namespaces {
+		workflows, err := source.WorkflowsContext(ctx, ident, namespace, ovv.Version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to Workflows for namespace %s", namespace)
+		}
+
+		for _, w := range workflows {
+			if w.Schedule == nil {
+				continue
+			}
+
+			jobs = append(jobs, Job{Tenant: ident, Namespace: namespace, TenantVersion: ovv.Version, Workflow: w})
+		}
+	}
+
+	return jobs, nil
+}
+
+// replaceJobs replaces the jobs of a tenant with the given ones. A job that already exists keeps its next run
+// if its schedule is unchanged. The lock must be held.
+func (s *Scheduler) replaceJobs(ident string, jobs []Job) {
+	replaced := map[string]bool{}
+
+	for _, j := range jobs {
+		j := j
+
+		key := j.Key()
+		replaced[key] = true
+
+		existing, exists := s.jobs[key]
+		if !exists {
+			s.jobs[key] = &j
+			continue
+		}
+
+		if reflect.DeepEqual(existing.Workflow.Schedule, j.Workflow.Schedule) {
+			j.Next, j.Due = existing.Next, existing.Due
+		}
+
+		*existing = j
+	}
+
+	for key, j := range s.jobs {
+		if j.Tenant == ident && !replaced[key] {
+			delete(s.jobs, key)
+		}
+	}
+}
+
+// schedule sets the job's next run to the first run after [from], plus jitter. The lock must be held.
+func (s *Scheduler) schedule(j *Job, from time.Time) error {
+	runs, err := j.Workflow.Schedule.NextRuns(from, 1)
+	if err != nil {
+		return errors.Wrap(err, "failed to NextRuns")
+	}
+
+	if len(runs) == 0 {
+		j.Next, j.Due = time.Time{}, time.Time{}
+		return nil
+	}
+
+	j.Due = runs[0]
+	j.Next = j.Due
+
+	if jitter := j.Workflow.Schedule.JitterDuration(); jitter > 0 {
+		j.Next = j.Next.Add(s.jitter(jitter))
+	}
+
+	return nil
+}
+
+// reschedule sets the job's next run to the run after the one that was due. Runs that are already in the past,
+// such as those missed while the scheduler wasn't running, are skipped rather than run all at once. The lock must be held.
+func (s *Scheduler) reschedule(j *Job, now time.Time) error {
+	if err := s.schedule(j, j.Due); err != nil {
+		return err
+	}
+
+	if j.Due.IsZero() || j.Due.After(now) {
+		return nil
+	}
+
+	return s.schedule(j, now)
+}
+
+// runDue starts every job that is due at [now], and returns the time that the next job is due, if any.
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) (time.Time, bool) {
+	var next time.Time
+
+	// errors are reported once the lock is released, so that the OnError callback may call Jobs.
+	var errs []error
+
+	s.lock.Lock()
+
+	for _, j := range s.jobs {
+		if !j.Next.IsZero() && !j.Next.After(now) {
+			run, running := s.running[j.Key()]
+
+			switch {
+			case !running:
+				s.running[j.Key()] = &runState{}
+				s.start(ctx, *j)
+			case j.Workflow.Schedule.OverlapPolicy() == tenant.OverlapQueue:
+				// only one run is queued, so that a stalled run isn't followed by a burst of them.
+				run.queued = true
+			}
+
+			if err := s.reschedule(j, now); err != nil {
+				errs = append(errs, errors.Wrapf(err, "failed to schedule %s", j.Key()))
+				j.Next, j.Due = time.Time{}, time.Time{}
+			}
+		}
+
+		if !j.Next.IsZero() && (next.IsZero() || j.Next.Before(next)) {
+			next = j.Next
+		}
+	}
+
+	s.lock.Unlock()
+
+	for _, err := range errs {
+		s.reportError(err)
+	}
+
+	return next, !next.IsZero()
+}
+
+// start runs the job in a new goroutine, followed by the latest version of the job if a run was queued while
+// it was running. The lock must be held.
+func (s *Scheduler) start(ctx context.Context, snapshot Job) {
+	s.runs.Add(1)
+
+	key := snapshot.Key()
+
+	go func() {
+		defer s.runs.Done()
+
+		for {
+			if err := s.executor(ctx, snapshot, NewRequest(snapshot)); err != nil {
+				s.reportError(errors.Wrapf(err, "failed to run %s", key))
+			}
+
+			s.lock.Lock()
+
+			run := s.running[key]
+			j, exists := s.jobs[key]
+
+			if !run.queued || !exists || ctx.Err() != nil {
+				delete(s.running, key)
+				s.lock.Unlock()
+
+				return
+			}
+
+			run.queued = false
+			snapshot = *j
+
+			s.lock.Unlock()
+		}
+	}()
+}
+
+// tenantVersion returns the version of the tenant that the scheduler's jobs were last reconciled with.
+func (s *Scheduler) tenantVersion(ident string) (int64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	version, exists := s.versions[ident]
+
+	return version, exists
+}
+
+func (s *Scheduler) reportError(err error) {
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
+
+// NewRequest returns a new request for a run of the job, with State seeded from the job's Schedule.State.
+func NewRequest(j Job) *request.CoordinatedRequest {
+	req := &request.CoordinatedRequest{
+		Method:      MethodSchedule,
+		URL:         j.Key(),
+		ID:          newRequestID(),
+		Body:        []byte{},
+		Headers:     map[string]string{},
+		RespHeaders: map[string]string{},
+		Params:      map[string]string{},
+		State:       map[string][]byte{},
+	}
+
+	if j.Workflow.Schedule != nil {
+		for k, v := range j.Workflow.Schedule.State {
+			req.State[k] = []byte(v)
+		}
+	}
+
+	return req
+}
+
+// newRequestID returns a random 128-bit hex identifier.
+func newRequestID() string {
+	b := make([]byte, 16)
+
+	// crypto/rand.Read only fails if the system's randomness source is unavailable.
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,423 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/request"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// fakeClock is a Clock whose time only moves when the test advances it.
+type fakeClock struct {
+	now     time.Time
+	waiters []waiter
+	waiting chan struct{}
+	lock    sync.Mutex
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waiting: make(chan struct{}, 100)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+
+	f.waiters = append(f.waiters, waiter{at: f.now.Add(d), ch: ch})
+	f.waiting <- struct{}{}
+
+	return ch
+}
+
+// Advance moves the clock forward by [d], waking any waiters that are due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.now = f.now.Add(d)
+
+	remaining := []waiter{}
+
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			remaining = append(remaining, w)
+			continue
+		}
+
+		w.ch <- f.now
+	}
+
+	f.waiters = remaining
+}
+
+// scheduleSource is a Source with a single tenant, whose workflows can be changed by the test.
+type scheduleSource struct {
+	version   int64
+	workflows []tenant.Workflow
+	lock      sync.Mutex
+}
+
+func (s *scheduleSource) set(version int64, workflows ...tenant.Workflow) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.version, s.workflows = version, workflows
+}
+
+func (s *scheduleSource) Start() error { return nil }
+
+func (s *scheduleSource) State() (*system.State, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return &system.State{SystemVersion: s.version}, nil
+}
+
+func (s *scheduleSource) Overview() (*system.Overview, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return &system.Overview{
+		State:      system.State{SystemVersion: s.version},
+		TenantRefs: system.References{Identifiers: map[string]int64{"com.suborbital.acme": s.version}},
+	}, nil
+}
+
+func (s *scheduleSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return &system.TenantOverview{Identifier: ident, Version: s.version}, nil
+}
+
+func (s *scheduleSource) GetModule(string) (*tenant.Module, error) {
+	return nil, system.ErrModuleNotFound
+}
+
+func (s *scheduleSource) Workflows(string, string, int64) ([]tenant.Workflow, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.workflows, nil
+}
+
+func (s *scheduleSource) Connections(string, string, int64) ([]tenant.Connection, error) {
+	return []tenant.Connection{}, nil
+}
+
+func (s *scheduleSource) Authentication(string, string, int64) (*tenant.Authentication, error) {
+	return nil, system.ErrTenantNotFound
+}
+
+func (s *scheduleSource) Capabilities(string, string, int64) (*capabilities.CapabilityConfig, error) {
+	config := capabilities.DefaultCapabilityConfig()
+	return &config, nil
+}
+
+type run struct {
+	job Job
+	req *request.CoordinatedRequest
+}
+
+func TestSchedulerRun(t *testing.T) {
+	clock := newFakeClock(time.Date(2023, 3, 24, 8, 59, 0, 0, time.UTC))
+
+	source := &scheduleSource{}
+	source.set(1,
+		tenant.Workflow{Name: "report", Schedule: &tenant.Schedule{Cron: "0 9 * * *", State: map[string]string{"format": "pdf"}}},
+		tenant.Workflow{Name: "unscheduled"},
+	)
+
+	runs := make(chan run, 10)
+
+	s := NewScheduler(source, func(_ context.Context, job Job, req *request.CoordinatedRequest) error {
+		runs <- run{job: job, req: req}
+		return nil
+	}, WithClock(clock), OnError(func(err error) { t.Error(err) }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- s.Run(ctx, time.Hour) }()
+
+	<-clock.waiting
+
+	jobs := s.Jobs()
+	if len(jobs) != 1 || jobs[0].Key() != "com.suborbital.acme/default/report" {
+		t.Fatalf("expected a single scheduled job, got %+v", jobs)
+	}
+
+	if !jobs[0].Next.Equal(time.Date(2023, 3, 24, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the job to be due at 9am, got %s", jobs[0].Next)
+	}
+
+	clock.Advance(time.Minute)
+
+	r := <-runs
+	if r.req.Method != MethodSchedule || string(r.req.State["format"]) != "pdf" || r.req.ID == "" {
+		t.Errorf("expected a request seeded from the schedule's state, got %+v", r.req)
+	}
+
+	<-clock.waiting
+
+	// a new tenant version replaces the schedule once the next reconcile runs.
+	source.set(2, tenant.Workflow{Name: "report", Schedule: &tenant.Schedule{Every: tenant.ScheduleEvery{Minutes: 30}}})
+
+	clock.Advance(time.Hour)
+	<-clock.waiting
+
+	jobs = s.Jobs()
+	if len(jobs) != 1 || jobs[0].TenantVersion != 2 || !jobs[0].Next.Equal(time.Date(2023, 3, 24, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected the job to be rescheduled every 30 minutes, got %+v", jobs)
+	}
+
+	clock.Advance(30 * time.Minute)
+
+	if r := <-runs; r.job.TenantVersion != 2 {
+		t.Errorf("expected a run of the new version, got %+v", r.job)
+	}
+
+	<-clock.waiting
+
+	source.set(3)
+
+	clock.Advance(30 * time.Minute)
+	<-clock.waiting
+
+	if jobs := s.Jobs(); len(jobs) != 0 {
+		t.Errorf("expected the job to be removed, got %+v", jobs)
+	}
+
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Error("expected Run to return context.Canceled, got:", err)
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	for _, policy := range []tenant.OverlapPolicy{tenant.OverlapSkip, tenant.OverlapQueue} {
+		clock := newFakeClock(time.Date(2023, 3, 24, 9, 0, 0, 0, time.UTC))
+
+		source := &scheduleSource{}
+		source.set(1, tenant.Workflow{Name: "sync", Schedule: &tenant.Schedule{Every: tenant.ScheduleEvery{Minutes: 1}, Overlap: policy}})
+
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+
+		s := NewScheduler(source, func(context.Context, Job, *request.CoordinatedRequest) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}, WithClock(clock))
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error)
+		go func() { done <- s.Run(ctx, time.Hour) }()
+
+		<-clock.waiting
+
+		// the first run starts, and is still going when the next two are due.
+		for i := 0; i < 3; i++ {
+			clock.Advance(time.Minute)
+			<-clock.waiting
+		}
+
+		<-started
+
+		release <- struct{}{}
+
+		// however many runs were due, only one is queued.
+		expected := 0
+		if policy == tenant.OverlapQueue {
+			expected = 1
+		}
+
+		for i := 0; i < expected; i++ {
+			<-started
+			release <- struct{}{}
+		}
+
+		cancel()
+		<-done
+
+		if len(started) != 0 {
+			t.Errorf("%s: expected %d queued runs, got more", policy, expected)
+		}
+	}
+}
+
+func TestSchedulerReaddedWhileRunning(t *testing.T) {
+	clock := newFakeClock(time.Date(2023, 3, 24, 9, 0, 0, 0, time.UTC))
+
+	workflow := tenant.Workflow{Name: "sync", Schedule: &tenant.Schedule{Every: tenant.ScheduleEvery{Minutes: 1}}}
+
+	source := &scheduleSource{}
+	source.set(1, workflow)
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+
+	s := NewScheduler(source, func(context.Context, Job, *request.CoordinatedRequest) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, WithClock(clock))
+
+	ctx := context.Background()
+
+	if err := s.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Minute)
+	s.runDue(ctx, clock.Now())
+
+	<-started
+
+	// the job is removed and re-added while its first run is still going.
+	for version, workflows := range [][]tenant.Workflow{nil, {workflow}} {
+		source.set(int64(version+2), workflows...)
+
+		if err := s.Reconcile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.Advance(time.Minute)
+	s.runDue(ctx, clock.Now())
+
+	close(release)
+	s.runs.Wait()
+
+	if len(started) != 0 {
+		t.Error("expected the re-added job not to start while its previous run is in progress")
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	clock := newFakeClock(time.Date(2023, 3, 24, 9, 0, 0, 0, time.UTC))
+
+	source := &scheduleSource{}
+	source.set(1, tenant.Workflow{Name: "sync", Schedule: &tenant.Schedule{Cron: "@hourly", Jitter: "5m"}})
+
+	s := NewScheduler(source, func(context.Context, Job, *request.CoordinatedRequest) error { return nil },
+		WithClock(clock),
+		WithJitter(func(max time.Duration) time.Duration { return max / 5 }),
+	)
+
+	if err := s.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if jobs := s.Jobs(); len(jobs) != 1 || !jobs[0].Next.Equal(time.Date(2023, 3, 24, 10, 1, 0, 0, time.UTC)) {
+		t.Errorf("expected the job to be due a minute after 10am, got %+v", jobs)
+	}
+}
+
+func TestSchedulerIntervalDrift(t *testing.T) {
+	clock := newFakeClock(time.Date(2023, 3, 24, 9, 0, 0, 0, time.UTC))
+
+	source := &scheduleSource{}
+	source.set(1, tenant.Workflow{Name: "sync", Schedule: &tenant.Schedule{Every: tenant.ScheduleEvery{Minutes: 1}, Jitter: "30s"}})
+
+	s := NewScheduler(source, func(context.Context, Job, *request.CoordinatedRequest) error { return nil },
+		WithClock(clock),
+		WithJitter(func(time.Duration) time.Duration { return 20 * time.Second }),
+	)
+
+	if err := s.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// the run is started late, but the next one is still a minute after the previous one was due.
+	clock.Advance(time.Minute + 25*time.Second)
+	s.runDue(ctx, clock.Now())
+	s.runs.Wait()
+
+	if jobs := s.Jobs(); !jobs[0].Due.Equal(time.Date(2023, 3, 24, 9, 2, 0, 0, time.UTC)) || !jobs[0].Next.Equal(time.Date(2023, 3, 24, 9, 2, 20, 0, time.UTC)) {
+		t.Errorf("expected the next run to be scheduled from when the last one was due, got %+v", jobs[0])
+	}
+
+	// runs that were missed are skipped.
+	clock.Advance(3 * time.Minute)
+	s.runDue(ctx, clock.Now())
+	s.runs.Wait()
+
+	if jobs := s.Jobs(); !jobs[0].Next.After(clock.Now()) {
+		t.Errorf("expected missed runs to be skipped, got %+v", jobs[0])
+	}
+}
+
+func TestSchedulerInvalidInterval(t *testing.T) {
+	s := NewScheduler(&scheduleSource{}, func(context.Context, Job, *request.CoordinatedRequest) error { return nil })
+
+	if err := s.Run(context.Background(), 0); err == nil {
+		t.Error("expected Run to reject a non-positive interval")
+	}
+}
+
+func TestSchedulerErrorCallback(t *testing.T) {
+	clock := newFakeClock(time.Date(2023, 3, 24, 9, 0, 0, 0, time.UTC))
+
+	source := &scheduleSource{}
+	source.set(1, tenant.Workflow{Name: "sync", Schedule: &tenant.Schedule{Every: tenant.ScheduleEvery{Minutes: 1}}})
+
+	var s *Scheduler
+
+	reported := make(chan []Job, 1)
+
+	s = NewScheduler(source, func(context.Context, Job, *request.CoordinatedRequest) error { return nil },
+		WithClock(clock),
+		OnError(func(error) { reported <- s.Jobs() }),
+	)
+
+	if err := s.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// break the schedule so that rescheduling the job after its run fails.
+	s.jobs["com.suborbital.acme/default/sync"].Workflow.Schedule = &tenant.Schedule{}
+
+	clock.Advance(time.Minute)
+
+	go s.runDue(context.Background(), clock.Now())
+
+	select {
+	case jobs := <-reported:
+		if len(jobs) != 1 || !jobs[0].Next.IsZero() {
+			t.Errorf("expected the job to be left unscheduled, got %+v", jobs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the error, the callback may have deadlocked")
+	}
+
+	s.runs.Wait()
+}
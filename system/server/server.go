@@ -0,0 +1,518 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+const (
+	// watchKeepalive is how often a comment is sent to idle watch streams to keep connections open.
+	watchKeepalive = 15 * time.Second
+
+	// watchPollInterval is how often the State of a source that isn't watchable is polled for changes.
+	watchPollInterval = time.Second
+)
+
+// grantKey is the echo context key that the verified system.Grant for a request is stored under.
+const grantKey = "systemGrant"
+
+// Server exposes a system.Source over HTTP using the routes consumed by client.HTTPSource.
+type Server struct {
+	source   system.Source
+	verifier system.CredentialVerifier
+	deltas   *system.DeltaTracker
+	echo     *echo.Echo
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithVerifier configures the Server to authenticate every request using the given verifier,
+// restricting access to the tenants allowed by each credential's grant. It takes precedence over creds passed to New.
+func WithVerifier(verifier system.CredentialVerifier) Option {
+	return func(s *Server) {
+		s.verifier = verifier
+	}
+}
+
+// New creates a new Server that serves the given source. If creds is non-nil, every request
+// must carry a matching Authorization header.
+func New(source system.Source, creds system.Credential, opts ...Option) *Server {
+	s := &Server{
+		source: source,
+		deltas: system.NewDeltaTracker(system.DefaultDeltaHistory),
+		echo:   echo.New(),
+	}
+
+	if creds != nil {
+		grant := system.GrantForToken(creds.Value())
+		grant.Scheme = creds.Scheme()
+
+		// this cannot fail, as the grant's hash was just encoded.
+		s.verifier, _ = system.NewHashedTokenStore(grant)
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.echo.HideBanner = true
+	s.echo.HidePort = true
+
+	s.routes()
+
+	return s
+}
+
+// Start starts the server listening on the given address. It blocks until the server is shut down.
+func (s *Server) Start(addr string) error {
+	if err := s.echo.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "failed to echo.Start")
+	}
+
+	return nil
+}
+
+// Shutdown gracefully shuts down the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.echo.Shutdown(ctx); err != nil {
+		return errors.Wrap(err, "failed to echo.Shutdown")
+	}
+
+	return nil
+}
+
+// ServeHTTP allows the Server to be mounted as an http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.echo.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	v1 := s.echo.Group("/system/v1", s.authMiddleware)
+
+	v1.GET("/state", s.stateHandler)
+	v1.GET("/overview", s.overviewHandler)
+	v1.GET("/overview/delta/:since", s.overviewDeltaHandler)
+	v1.GET("/tenant/:ident", s.tenantOverviewHandler)
+	v1.GET("/module/:ident/:ref/*", s.moduleHandler)
+	v1.GET("/wasm/:ident/:ref/*", s.moduleDataHandler)
+	v1.GET("/workflows/:ident/:namespace/:version", s.workflowsHandler)
+	v1.GET("/connections/:ident/:namespace/:version", s.connectionsHandler)
+	v1.GET("/authentication/:ident/:namespace/:version", s.authenticationHandler)
+	v1.GET("/caps/:ident/:namespace/:version", s.capabilitiesHandler)
+	v1.GET("/watch", s.watchHandler)
+}
+
+// authMiddleware rejects requests whose Authorization header is not accepted by the verifier,
+// or that address a tenant the credential's grant does not allow.
+func (s *Server) authMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.verifier == nil {
+			return next(c)
+		}
+
+		creds, err := system.CredentialFromHeader(c.Request().Header.Get(echo.HeaderAuthorization))
+		if err != nil {
+			return httpError(err)
+		}
+
+		grant, err := s.verifier.Verify(c.Request().Context(), creds)
+		if err != nil {
+			return httpError(err)
+		}
+
+		if ident := c.Param("ident"); ident != "" && !grant.AllowsTenant(ident) {
+			return echo.NewHTTPError(http.StatusUnauthorized, system.ErrAuthenticationFailed.Error())
+		}
+
+		c.Set(grantKey, grant)
+
+		return next(c)
+	}
+}
+
+// allowsTenant returns true if the request's grant (if any) allows access to the given tenant.
+func allowsTenant(c echo.Context, ident string) bool {
+	grant, ok := c.Get(grantKey).(*system.Grant)
+	if !ok {
+		return true
+	}
+
+	return grant.AllowsTenant(ident)
+}
+
+func (s *Server) stateHandler(c echo.Context) error {
+	state, err := s.source.State()
+	if err != nil {
+		return httpError(err)
+	}
+
+	return c.JSON(http.StatusOK, state)
+}
+
+func (s *Server) overviewHandler(c echo.Context) error {
+	ovv, err := s.source.Overview()
+	if err != nil {
+		return httpError(err)
+	}
+
+	// snapshots are recorded so that this client's next sync can be served incrementally.
+	s.deltas.Record(ovv)
+
+	// only reveal the tenants that the request's credential may access. The source's overview is
+	// copied rather than modified, as it may be shared with other callers.
+	filtered := &system.Overview{
+		State: ovv.State,
+		TenantRefs: system.References{
+			Identifiers: map[string]int64{},
+		},
+	}
+
+	for ident, version := range ovv.TenantRefs.Identifiers {
+		if allowsTenant(c, ident) {
+			filtered.TenantRefs.Identifiers[ident] = version
+		}
+	}
+
+	return c.JSON(http.StatusOK, filtered)
+}
+
+// overviewDeltaHandler returns the changes to the system's tenants since the given system version. If the source
+// doesn't implement system.DeltaSource, the delta is computed from the overview snapshots the server has recorded.
+func (s *Server) overviewDeltaHandler(c echo.Context) error {
+	since, err := strconv.ParseInt(c.Param("since"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "since must be an integer")
+	}
+
+	var delta *system.OverviewDelta
+
+	if deltaSource, ok := s.source.(system.DeltaSource); ok {
+		delta, err = deltaSource.OverviewSince(since)
+		if err != nil {
+			return httpError(err)
+		}
+	} else {
+		ovv, err := s.source.Overview()
+		if err != nil {
+			return httpError(err)
+		}
+
+		delta = s.deltas.Since(since, ovv)
+	}
+
+	filtered := &system.OverviewDelta{
+		State:   delta.State,
+		Since:   delta.Since,
+		Full:    delta.Full,
+		Added:   map[string]int64{},
+		Changed: map[string]int64{},
+		Removed: []string{},
+	}
+
+	for ident, version := range delta.Added {
+		if allowsTenant(c, ident) {
+			filtered.Added[ident] = version
+		}
+	}
+
+	for ident, version := range delta.Changed {
+		if allowsTenant(c, ident) {
+			filtered.Changed[ident] = version
+		}
+	}
+
+	for _, ident := range delta.Removed {
+		if allowsTenant(c, ident) {
+			filtered.Removed = append(filtered.Removed, ident)
+		}
+	}
+
+	return c.JSON(http.StatusOK, filtered)
+}
+
+func (s *Server) tenantOverviewHandler(c echo.Context) error {
+	ovv, err := s.source.TenantOverview(c.Param("ident"))
+	if err != nil {
+		return httpError(err)
+	}
+
+	return c.JSON(http.StatusOK, ovv)
+}
+
+func (s *Server) moduleHandler(c echo.Context) error {
+	FQMN, err := moduleFQMN(c)
+	if err != nil {
+		return err
+	}
+
+	module, err := s.source.GetModule(FQMN)
+	if err != nil {
+		return httpError(err)
+	}
+
+	// clients that cache module bytes fetch them from the binary endpoint instead, so they can be omitted.
+	if c.QueryParam("data") == "false" && module.WasmRef != nil {
+		withoutData := *module
+		withoutData.WasmRef = &tenant.WasmModuleRef{Name: module.WasmRef.Name, FQMN: module.WasmRef.FQMN}
+
+		return c.JSON(http.StatusOK, withoutData)
+	}
+
+	return c.JSON(http.StatusOK, module)
+}
+
+// moduleDataHandler streams the module's Wasm bytes, responding with 304 Not Modified if the
+// request's If-None-Match header contains the module's current ETag.
+func (s *Server) moduleDataHandler(c echo.Context) error {
+	FQMN, err := moduleFQMN(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := system.ReadModule(c.Request().Context(), s.source, FQMN, "")
+	if err != nil {
+		return httpError(err)
+	}
+	defer data.Close()
+
+	c.Response().Header().Set("ETag", data.ETag)
+
+	if etagMatches(c.Request().Header.Get("If-None-Match"), data.ETag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if data.Size >= 0 {
+		c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(data.Size, 10))
+	}
+
+	return c.Stream(http.StatusOK, "application/wasm", data)
+}
+
+// moduleFQMN builds the FQMN addressed by a module route.
+func moduleFQMN(c echo.Context) (string, error) {
+	// the wildcard contains the (possibly nested) namespace followed by the module name, see fqmn.URLPath.
+	rest := strings.Trim(c.Param("*"), "/")
+
+	lastSlash := strings.LastIndex(rest, "/")
+	if lastSlash < 1 {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "module path must contain a namespace and name")
+	}
+
+	FQMN, err := fqmn.FromParts(c.Param("ident"), rest[:lastSlash], rest[lastSlash+1:], c.Param("ref"))
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return FQMN, nil
+}
+
+// etagMatches returns true if the If-None-Match header value contains the given ETag or is "*".
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || (candidate != "" && candidate == etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) workflowsHandler(c echo.Context) error {
+	ident, namespace, version, err := namespacedParams(c)
+	if err != nil {
+		return err
+	}
+
+	workflows, err := s.source.Workflows(ident, namespace, version)
+	if err != nil {
+		return httpError(err)
+	}
+
+	return c.JSON(http.StatusOK, workflows)
+}
+
+func (s *Server) connectionsHandler(c echo.Context) error {
+	ident, namespace, version, err := namespacedParams(c)
+	if err != nil {
+		return err
+	}
+
+	connections, err := s.source.Connections(ident, namespace, version)
+	if err != nil {
+		return httpError(err)
+	}
+
+	return c.JSON(http.StatusOK, connections)
+}
+
+func (s *Server) authenticationHandler(c echo.Context) error {
+	ident, namespace, version, err := namespacedParams(c)
+	if err != nil {
+		return err
+	}
+
+	authentication, err := s.source.Authentication(ident, namespace, version)
+	if err != nil {
+		return httpError(err)
+	}
+
+	return c.JSON(http.StatusOK, authentication)
+}
+
+func (s *Server) capabilitiesHandler(c echo.Context) error {
+	ident, namespace, version, err := namespacedParams(c)
+	if err != nil {
+		return err
+	}
+
+	caps, err := s.source.Capabilities(ident, namespace, version)
+	if err != nil {
+		return httpError(err)
+	}
+
+	return c.JSON(http.StatusOK, caps)
+}
+
+// watchHandler streams change events to the client as server-sent events until the client disconnects.
+// If the source is not a system.WatchableSource, its State is polled and EventSystemUpdated is sent on change.
+func (s *Server) watchHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var events <-chan system.Event
+
+	if watchable, ok := s.source.(system.WatchableSource); ok {
+		watched, err := watchable.Watch(ctx)
+		if err != nil {
+			return httpError(err)
+		}
+
+		events = watched
+	} else {
+		events = s.pollEvents(ctx)
+	}
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+	resp.Header().Set(echo.HeaderCacheControl, "no-cache")
+	resp.WriteHeader(http.StatusOK)
+	resp.Flush()
+
+	keepalive := time.NewTicker(watchKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(resp, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if e.Identifier != "" && !allowsTenant(c, e.Identifier) {
+				continue
+			}
+
+			data, err := json.Marshal(e)
+			if err != nil {
+				return nil
+			}
+
+			if _, err := fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return nil
+			}
+		}
+
+		resp.Flush()
+	}
+}
+
+// pollEvents polls the source's State and emits EventSystemUpdated whenever the system version changes.
+func (s *Server) pollEvents(ctx context.Context) <-chan system.Event {
+	events := make(chan system.Event)
+
+	go func() {
+		defer close(events)
+
+		var lastVersion int64
+
+		if state, err := s.source.State(); err == nil {
+			lastVersion = state.SystemVersion
+		}
+
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				state, err := s.source.State()
+				if err != nil || state.SystemVersion == lastVersion {
+					continue
+				}
+
+				lastVersion = state.SystemVersion
+
+				select {
+				case events <- system.Event{Type: system.EventSystemUpdated, SystemVersion: lastVersion}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// namespacedParams extracts the ident, namespace, and version path params shared by the namespaced routes.
+func namespacedParams(c echo.Context) (string, string, int64, error) {
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil {
+		return "", "", 0, echo.NewHTTPError(http.StatusBadRequest, "version must be an integer")
+	}
+
+	return c.Param("ident"), c.Param("namespace"), version, nil
+}
+
+// errorResponse is the body of a not-found response. Code identifies which not-found error occurred
+// (see system.ErrorCode), so that clients don't need to parse Message.
+type errorResponse struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// httpError converts errors returned by a system.Source into the appropriate HTTP error.
+func httpError(err error) error {
+	switch {
+	case errors.Is(err, system.ErrAuthenticationFailed):
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	case errors.Is(err, system.ErrTenantNotFound),
+		errors.Is(err, system.ErrNamespaceNotFound),
+		errors.Is(err, system.ErrVersionNotFound),
+		errors.Is(err, system.ErrModuleNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, errorResponse{Message: err.Error(), Code: system.ErrorCode(err)})
+	}
+
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/suborbital/systemspec/bundle"
+	"github.com/suborbital/systemspec/modulecache"
+	"github.com/suborbital/systemspec/system"
+	bundlesource "github.com/suborbital/systemspec/system/bundle"
+	"github.com/suborbital/systemspec/system/client"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+type testCredential struct{}
+
+func (testCredential) Scheme() string { return "Bearer" }
+func (testCredential) Value() string  { return "s3cr3t" }
+
+func writeTestBundle(t *testing.T) string {
+	t.Helper()
+
+	bundlePath := filepath.Join(t.TempDir(), "tenant.wasm.zip")
+	writeTestBundleVersion(t, bundlePath, 3)
+
+	return bundlePath
+}
+
+func writeTestBundleVersion(t *testing.T, bundlePath string, tenantVersion int64) {
+	t.Helper()
+
+	writeTestBundleModule(t, bundlePath, tenantVersion, "not really wasm")
+}
+
+func writeTestBundleModule(t *testing.T, bundlePath string, tenantVersion int64, wasm string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	conf := tenant.Config{
+		Identifier:    "com.suborbital.test",
+		TenantVersion: tenantVersion,
+		Modules: []tenant.Module{
+			{Name: "hello", Namespace: "default", Ref: "abc123"},
+		},
+		DefaultNamespace: tenant.NamespaceConfig{
+			Name: "default",
+			Workflows: []tenant.Workflow{
+				{Name: "greet", Steps: []tenant.WorkflowStep{{FQMN: "/name/default/hello"}}},
+			},
+		},
+	}
+
+	confBytes, err := conf.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wasmPath := filepath.Join(dir, "hello.wasm")
+	if err := os.WriteFile(wasmPath, []byte(wasm), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wasmFile, err := os.Open(wasmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer wasmFile.Close()
+
+	if err := bundle.Write(confBytes, []os.File{*wasmFile}, nil, bundlePath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	source := bundlesource.NewBundleSource(writeTestBundle(t))
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(source, testCredential{}))
+	defer srv.Close()
+
+	remote := client.NewHTTPSource(srv.URL, testCredential{})
+	if err := remote.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ovv, err := remote.Overview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ovv.TenantRefs.Identifiers["com.suborbital.test"] != 3 {
+		t.Errorf("expected tenant version 3, got %d", ovv.TenantRefs.Identifiers["com.suborbital.test"])
+	}
+
+	tovv, err := remote.TenantOverview("com.suborbital.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tovv.Config == nil || tovv.Config.Identifier != "com.suborbital.test" {
+		t.Error("tenant overview is missing its config")
+	}
+
+	workflows, err := remote.Workflows("com.suborbital.test", "default", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workflows) != 1 || workflows[0].Name != "greet" {
+		t.Errorf("unexpected workflows: %+v", workflows)
+	}
+
+	if _, err := remote.Workflows("com.suborbital.test", "default", 2); !errors.Is(err, system.ErrVersionNotFound) {
+		t.Error("expected ErrVersionNotFound for an unknown version, got:", err)
+	}
+
+	mod, err := remote.GetModule("fqmn://com.suborbital.test/default/hello@abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mod.WasmRef == nil || string(mod.WasmRef.Data) != "not really wasm" {
+		t.Error("module is missing its Wasm data")
+	}
+
+	if _, err := remote.GetModule("fqmn://com.suborbital.test/default/missing@abc123"); err == nil {
+		t.Error("expected an error for a missing module")
+	}
+
+	if _, err := remote.TenantOverview("com.suborbital.other"); err == nil {
+		t.Error("expected an error for an unknown tenant")
+	}
+}
+
+func TestServerModuleData(t *testing.T) {
+	bundlePath := writeTestBundle(t)
+
+	source := bundlesource.NewBundleSource(bundlePath)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(source, testCredential{}))
+	defer srv.Close()
+
+	remote := client.NewHTTPSource(srv.URL, testCredential{}).(system.ModuleReader)
+
+	const FQMN = "fqmn://com.suborbital.test/default/hello@abc123"
+
+	data, err := remote.ReadModule(context.Background(), FQMN, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := io.ReadAll(data)
+	data.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "not really wasm" || data.Size != int64(len(contents)) {
+		t.Errorf("unexpected module data %q with size %d", contents, data.Size)
+	}
+
+	if data.ETag != system.ModuleETag("abc123", 1) {
+		t.Errorf("unexpected ETag %s", data.ETag)
+	}
+
+	if _, err := remote.ReadModule(context.Background(), FQMN, data.ETag); !errors.Is(err, system.ErrModuleNotModified) {
+		t.Error("expected ErrModuleNotModified for a matching ETag, got:", err)
+	}
+
+	if _, err := remote.ReadModule(context.Background(), "fqmn://com.suborbital.test/default/missing@abc123", ""); !errors.Is(err, system.ErrModuleNotFound) {
+		t.Error("expected ErrModuleNotFound for a missing module, got:", err)
+	}
+
+	cache, err := modulecache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached := client.NewHTTPSource(srv.URL, testCredential{}, client.WithModuleCache(cache))
+
+	for i := 0; i < 2; i++ {
+		mod, err := cached.GetModule(FQMN)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mod.WasmRef == nil || string(mod.WasmRef.Data) != "not really wasm" {
+			t.Errorf("expected module data from the cache, got %+v", mod.WasmRef)
+		}
+	}
+
+	if etag, err := cache.Get(FQMN); err != nil || string(etag) != data.ETag {
+		t.Errorf("expected the module's ETag to be cached by its FQMN, got %q (%v)", etag, err)
+	}
+
+	// rebuilding the bundle under the same module ref must not leave the cached bytes in use.
+	writeTestBundleModule(t, bundlePath, 4, "rebuilt wasm")
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		mod, err := cached.GetModule(FQMN)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(mod.WasmRef.Data) == "rebuilt wasm" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the rebuilt module, got %q", mod.WasmRef.Data)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestServerRejectsBadCredentials(t *testing.T) {
+	source := bundlesource.NewBundleSource(writeTestBundle(t))
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(source, testCredential{}))
+	defer srv.Close()
+
+	remote := client.NewHTTPSource(srv.URL, nil)
+
+	if _, err := remote.State(); err == nil {
+		t.Error("expected unauthenticated request to fail")
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	bundlePath := writeTestBundle(t)
+
+	source := bundlesource.NewBundleSource(bundlePath)
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(source, testCredential{}))
+	defer srv.Close()
+
+	remote := client.NewHTTPSource(srv.URL, testCredential{}).(system.WatchableSource)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := remote.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// give the stream a moment to connect before changing the bundle.
+	time.Sleep(200 * time.Millisecond)
+
+	writeTestBundleVersion(t, bundlePath, 4)
+
+	select {
+	case e := <-events:
+		if e.Type != system.EventTenantVersionUpdated || e.TenantVersion != 4 {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestServerVerifierScopesTenants(t *testing.T) {
+	source := bundlesource.NewBundleSource(writeTestBundle(t))
+	if err := source.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	allowed := system.GrantForToken("allowed")
+
+	scoped := system.GrantForToken("scoped")
+	scoped.Tenants = []string{"com.suborbital.other"}
+
+	expired := system.GrantForToken("expired")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+
+	verifier, err := system.NewHashedTokenStore(allowed, scoped, expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(source, nil, WithVerifier(verifier)))
+	defer srv.Close()
+
+	if _, err := client.NewHTTPSource(srv.URL, system.NewCredential("Bearer", "allowed")).TenantOverview("com.suborbital.test"); err != nil {
+		t.Error("expected allowed token to succeed, got:", err)
+	}
+
+	scopedSource := client.NewHTTPSource(srv.URL, system.NewCredential("Bearer", "scoped"))
+
+	if _, err := scopedSource.TenantOverview("com.suborbital.test"); !errors.Is(err, system.ErrAuthenticationFailed) {
+		t.Error("expected scoped token to be rejected for another tenant, got:", err)
+	}
+
+	ovv, err := scopedSource.Overview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ovv.TenantRefs.Identifiers) != 0 {
+		t.Errorf("expected scoped overview to hide other tenants, got %v", ovv.TenantRefs.Identifiers)
+	}
+
+	if _, err := client.NewHTTPSource(srv.URL, system.NewCredential("Bearer", "expired")).State(); !errors.Is(err, system.ErrAuthenticationFailed) {
+		t.Error("expected expired token to be rejected, got:", err)
+	}
+}
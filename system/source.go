@@ -11,9 +11,46 @@ var (
 	ErrModuleNotFound       = errors.New("failed to find requested module")
 	ErrTenantNotFound       = errors.New("failed to find requested tenant")
 	ErrNamespaceNotFound    = errors.New("failed to find requested namespace")
+	ErrVersionNotFound      = errors.New("failed to find requested tenant version")
 	ErrAuthenticationFailed = errors.New("failed to authenticate")
+	ErrUnavailable          = errors.New("source is unavailable")
+	ErrModuleNotModified    = errors.New("module has not been modified")
 )
 
+// errorCodes are the codes that identify the not-found errors in error responses, so that clients
+// can tell them apart without parsing messages. They are checked in order, most specific first.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrVersionNotFound, "versionNotFound"},
+	{ErrNamespaceNotFound, "namespaceNotFound"},
+	{ErrModuleNotFound, "moduleNotFound"},
+	{ErrTenantNotFound, "tenantNotFound"},
+}
+
+// ErrorCode returns the code that identifies err in an error response, or an empty string if it has none.
+func ErrorCode(err error) string {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+
+	return ""
+}
+
+// ErrorForCode returns the error identified by the given code, or nil if the code is unknown.
+func ErrorForCode(code string) error {
+	for _, e := range errorCodes {
+		if e.code == code {
+			return e.err
+		}
+	}
+
+	return nil
+}
+
 // Source describes how an entire system relays its state to a client.
 type Source interface {
 	// Start indicates to the Source that it should prepare for system startup.
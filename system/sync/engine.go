@@ -0,0 +1,403 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// DefaultConcurrency is the number of tenants materialized at once by a SyncEngine that doesn't configure its own.
+const DefaultConcurrency = 4
+
+// Option configures a SyncEngine.
+type Option func(*SyncEngine)
+
+// WithConcurrency sets the maximum number of tenants that are materialized at once.
+func WithConcurrency(n int) Option {
+	return func(s *SyncEngine) {
+		if n > 0 {
+			s.concurrency = n
+		}
+	}
+}
+
+// OnTenantAdded sets a callback that is called after a tenant is added to the registry.
+func OnTenantAdded(fn func(t *Tenant)) Option {
+	return func(s *SyncEngine) {
+		s.onAdded = fn
+	}
+}
+
+// OnTenantUpdated sets a callback that is called after a tenant in the registry is replaced by a new version.
+func OnTenantUpdated(fn func(previous, current *Tenant)) Option {
+	return func(s *SyncEngine) {
+		s.onUpdated = fn
+	}
+}
+
+// OnTenantRemoved sets a callback that is called after a tenant is removed from the registry.
+func OnTenantRemoved(fn func(previous *Tenant)) Option {
+	return func(s *SyncEngine) {
+		s.onRemoved = fn
+	}
+}
+
+// OnError sets a callback that is called when a sync started by Run fails, including when only some tenants
+// fail to materialize (see MaterializeError).
+func OnError(fn func(err error)) Option {
+	return func(s *SyncEngine) {
+		s.onError = fn
+	}
+}
+
+// SyncEngine keeps a Registry in sync with a Source, fetching only the changes since the last sync
+// if the Source implements system.DeltaSource, and materializing each added or changed tenant.
+type SyncEngine struct {
+	source      system.Source
+	registry    *Registry
+	concurrency int
+
+	onAdded   func(t *Tenant)
+	onUpdated func(previous, current *Tenant)
+	onRemoved func(previous *Tenant)
+	onError   func(err error)
+
+	// failed maps each tenant that failed to materialize to the version that was wanted, so that it
+	// is retried by the next sync even if the system version hasn't changed.
+	failed map[string]int64
+
+	// lock ensures that only one sync runs at a time.
+	lock sync.Mutex
+}
+
+// MaterializeError is returned by a sync in which some tenants failed to materialize. The rest of the
+// delta is still applied, the failed tenants keep their previous version in the registry (if any), and
+// they are retried by the next sync.
+type MaterializeError struct {
+	// Tenants maps each tenant that failed to materialize to its error.
+	Tenants map[string]error
+}
+
+func (m *MaterializeError) Error() string {
+	idents := make([]string, 0, len(m.Tenants))
+	for ident := range m.Tenants {
+		idents = append(idents, ident)
+	}
+
+	sort.Strings(idents)
+
+	msgs := make([]string, len(idents))
+	for i, ident := range idents {
+		msgs[i] = fmt.Sprintf("%s: %s", ident, m.Tenants[ident])
+	}
+
+	return fmt.Sprintf("failed to materialize %d tenants: %s", len(idents), strings.Join(msgs, "; "))
+}
+
+// NewSyncEngine creates a SyncEngine that syncs [registry] from [source].
+func NewSyncEngine(source system.Source, registry *Registry, opts ...Option) *SyncEngine {
+	s := &SyncEngine{
+		source:      source,
+		registry:    registry,
+		concurrency: DefaultConcurrency,
+		failed:      map[string]int64{},
+		lock:        sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Run syncs the registry every [interval] until ctx is cancelled, returning ctx.Err().
+// Errors are reported to the OnError callback and the sync is retried on the next interval.
+func (s *SyncEngine) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SyncContext(ctx); err != nil && ctx.Err() == nil && s.onError != nil {
+			s.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync brings the registry up to date with the source and returns the delta that was applied.
+// If the system version hasn't changed since the last sync, nil is returned.
+func (s *SyncEngine) Sync() (*system.OverviewDelta, error) {
+	return s.SyncContext(context.Background())
+}
+
+// SyncContext is Sync with a context that bounds every request made to the source. If some added or changed
+// tenants fail to materialize, the rest of the delta is applied and both the delta and a *MaterializeError are
+// returned. The failed tenants are retried by the next sync, even if the system version hasn't changed.
+func (s *SyncEngine) SyncContext(ctx context.Context) (*system.OverviewDelta, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	source := system.WithContext(s.source)
+
+	state, err := source.StateContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to State")
+	}
+
+	since := s.registry.SystemVersion()
+	if since != 0 && state.SystemVersion == since && len(s.failed) == 0 {
+		return nil, nil
+	}
+
+	delta := &system.OverviewDelta{State: *state, Since: since, Added: map[string]int64{}, Changed: map[string]int64{}}
+
+	if since == 0 || state.SystemVersion != since {
+		if delta, err = s.delta(ctx, source, since); err != nil {
+			return nil, err
+		}
+	}
+
+	materialized, failures := s.materializeAll(ctx, source, delta)
+
+	if err := ctx.Err(); err != nil {
+		// failures caused by the cancellation say nothing about the tenants, so nothing is applied.
+		return nil, err
+	}
+
+	for ident := range s.failed {
+		if _, failed := failures[ident]; !failed {
+			delete(s.failed, ident)
+		}
+	}
+
+	for _, c := range s.registry.apply(delta, materialized) {
+		switch {
+		case c.current == nil:
+			if s.onRemoved != nil {
+				s.onRemoved(c.previous)
+			}
+		case c.previous == nil:
+			if s.onAdded != nil {
+				s.onAdded(c.current)
+			}
+		default:
+			if s.onUpdated != nil {
+				s.onUpdated(c.previous, c.current)
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return delta, &MaterializeError{Tenants: failures}
+	}
+
+	return delta, nil
+}
+
+// delta gets the changes since the given system version from the source, computing it
+// from the full Overview if the source can't provide it directly.
+func (s *SyncEngine) delta(ctx context.Context, source system.ContextSource, since int64) (*system.OverviewDelta, error) {
+	if deltaSource, ok := s.source.(system.DeltaSource); ok {
+		var delta *system.OverviewDelta
+		var err error
+
+		if contextSource, ok := s.source.(system.DeltaContextSource); ok {
+			delta, err = contextSource.OverviewSinceContext(ctx, since)
+		} else {
+			delta, err = deltaSource.OverviewSince(since)
+		}
+
+		if err == nil {
+			return delta, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "failed to OverviewSince")
+		}
+
+		// servers that predate the delta endpoint will return an error, so fall back to the full overview.
+	}
+
+	ovv, err := source.OverviewContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Overview")
+	}
+
+	return system.DiffIdentifiers(since, ovv.State, s.registry.Tenants(), ovv.TenantRefs.Identifiers), nil
+}
+
+// materializeAll materializes every added or changed tenant in the delta, and every tenant that failed to
+// materialize in a previous sync, at most s.concurrency at a time. Tenants whose version is already in the
+// registry are reused rather than fetched again, as is the registry's version of any tenant that fails.
+func (s *SyncEngine) materializeAll(ctx context.Context, source system.ContextSource, delta *system.OverviewDelta) (map[string]*Tenant, map[string]error) {
+	wanted := map[string]int64{}
+
+	for ident, version := range s.failed {
+		// a Full delta lists every tenant, so a failed tenant that isn't listed has been removed.
+		if _, exists := delta.Added[ident]; delta.Full && !exists {
+			continue
+		}
+
+		wanted[ident] = version
+	}
+
+	for _, ident := range delta.Removed {
+		delete(wanted, ident)
+	}
+
+	for _, versions := range []map[string]int64{delta.Added, delta.Changed} {
+		for ident, version := range versions {
+			wanted[ident] = version
+		}
+	}
+
+	pending := []string{}
+	materialized := map[string]*Tenant{}
+
+	for ident, version := range wanted {
+		if existing, exists := s.registry.Tenant(ident); exists && existing.Version() == version {
+			materialized[ident] = existing
+			continue
+		}
+
+		pending = append(pending, ident)
+	}
+
+	failures := map[string]error{}
+
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, s.concurrency)
+
+	for _, ident := range pending {
+		ident := ident
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			t, err := materialize(ctx, source, ident)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				failures[ident] = err
+				s.failed[ident] = wanted[ident]
+
+				// keep the version already in the registry, so that a Full delta doesn't remove it.
+				if existing, exists := s.registry.Tenant(ident); exists {
+					materialized[ident] = existing
+				}
+
+				return
+			}
+
+			materialized[ident] = t
+		}()
+	}
+
+	wg.Wait()
+
+	return materialized, failures
+}
+
+// materialize fetches the overview, namespaces, and modules of the given tenant.
+func materialize(ctx context.Context, source system.ContextSource, ident string) (*Tenant, error) {
+	ovv, err := source.TenantOverviewContext(ctx, ident)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to TenantOverview")
+	}
+
+	t := &Tenant{
+		Overview:   ovv,
+		Namespaces: map[string]*Namespace{},
+		Modules:    map[string]*tenant.Module{},
+	}
+
+	namespaces := []string{fqmn.NamespaceDefault}
+
+	if ovv.Config != nil {
+		for _, ns := range ovv.Config.Namespaces {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	for _, name := range namespaces {
+		ns, err := materializeNamespace(ctx, source, ident, name, ovv.Version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to materialize namespace %s", name)
+		}
+
+		t.Namespaces[name] = ns
+	}
+
+	if ovv.Config == nil {
+		return t, nil
+	}
+
+	for _, m := range ovv.Config.Modules {
+		FQMN := m.FQMN
+		if FQMN == "" {
+			FQMN, err = fqmn.FromParts(ident, m.Namespace, m.Name, m.Ref)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to FromParts for module %s", m.Name)
+			}
+		}
+
+		mod, err := source.GetModuleContext(ctx, FQMN)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to GetModule %s", FQMN)
+		}
+
+		t.Modules[FQMN] = mod
+	}
+
+	return t, nil
+}
+
+// materializeNamespace fetches the workflows, connections, authentication, and capabilities of a namespace.
+func materializeNamespace(ctx context.Context, source system.ContextSource, ident, namespace string, version int64) (*Namespace, error) {
+	var err error
+
+	ns := &Namespace{Name: namespace}
+
+	if ns.Workflows, err = source.WorkflowsContext(ctx, ident, namespace, version); err != nil {
+		return nil, errors.Wrap(err, "failed to Workflows")
+	}
+
+	if ns.Connections, err = source.ConnectionsContext(ctx, ident, namespace, version); err != nil {
+		return nil, errors.Wrap(err, "failed to Connections")
+	}
+
+	// a namespace without authentication is reported as not found by some sources.
+	if ns.Authentication, err = source.AuthenticationContext(ctx, ident, namespace, version); err != nil && !errors.Is(err, system.ErrTenantNotFound) {
+		return nil, errors.Wrap(err, "failed to Authentication")
+	}
+
+	if ns.Capabilities, err = source.CapabilitiesContext(ctx, ident, namespace, version); err != nil {
+		return nil, errors.Wrap(err, "failed to Capabilities")
+	}
+
+	return ns, nil
+}
@@ -0,0 +1,193 @@
+package sync
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/system/client"
+	"github.com/suborbital/systemspec/system/server"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// overviewSource is a Source whose overview can be changed by the test. Each tenant has a single
+// module, and a tenant ident containing "broken" fails to materialize until fixed is set.
+type overviewSource struct {
+	ovv   *system.Overview
+	fixed bool
+}
+
+func (o *overviewSource) Start() error { return nil }
+
+func (o *overviewSource) State() (*system.State, error) {
+	return &o.ovv.State, nil
+}
+
+func (o *overviewSource) Overview() (*system.Overview, error) {
+	return o.ovv, nil
+}
+
+func (o *overviewSource) TenantOverview(ident string) (*system.TenantOverview, error) {
+	version, exists := o.ovv.TenantRefs.Identifiers[ident]
+	if !exists {
+		return nil, system.ErrTenantNotFound
+	}
+
+	ovv := &system.TenantOverview{
+		Identifier: ident,
+		Version:    version,
+		Config: &tenant.Config{
+			Identifier:    ident,
+			TenantVersion: version,
+			Modules:       []tenant.Module{{Name: "mod", Namespace: "default", Ref: "abc"}},
+		},
+	}
+
+	return ovv, nil
+}
+
+func (o *overviewSource) GetModule(FQMN string) (*tenant.Module, error) {
+	if strings.Contains(FQMN, "broken") && !o.fixed {
+		return nil, system.ErrModuleNotFound
+	}
+
+	return &tenant.Module{Name: "mod", Namespace: "default", Ref: "abc", FQMN: FQMN}, nil
+}
+
+func (o *overviewSource) Workflows(string, string, int64) ([]tenant.Workflow, error) {
+	return []tenant.Workflow{{Name: "wf"}}, nil
+}
+
+func (o *overviewSource) Connections(string, string, int64) ([]tenant.Connection, error) {
+	return []tenant.Connection{}, nil
+}
+
+func (o *overviewSource) Authentication(string, string, int64) (*tenant.Authentication, error) {
+	return nil, system.ErrTenantNotFound
+}
+
+func (o *overviewSource) Capabilities(string, string, int64) (*capabilities.CapabilityConfig, error) {
+	config := capabilities.DefaultCapabilityConfig()
+	return &config, nil
+}
+
+func setOverview(o *overviewSource, systemVersion int64, tenants map[string]int64) {
+	o.ovv = &system.Overview{
+		State:      system.State{SystemVersion: systemVersion},
+		TenantRefs: system.References{Identifiers: tenants},
+	}
+}
+
+func TestSyncEngineDeltas(t *testing.T) {
+	source := &overviewSource{}
+	setOverview(source, 1, map[string]int64{"one": 1, "two": 1})
+
+	srv := httptest.NewServer(server.New(source, nil))
+	defer srv.Close()
+
+	var added, updated, removed []string
+
+	registry := NewRegistry()
+	engine := NewSyncEngine(client.NewHTTPSource(srv.URL, nil), registry,
+		WithConcurrency(2),
+		OnTenantAdded(func(t *Tenant) { added = append(added, t.Overview.Identifier) }),
+		OnTenantUpdated(func(_, current *Tenant) { updated = append(updated, current.Overview.Identifier) }),
+		OnTenantRemoved(func(previous *Tenant) { removed = append(removed, previous.Overview.Identifier) }),
+	)
+
+	delta, err := engine.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !delta.Full || len(delta.Added) != 2 {
+		t.Errorf("expected a full initial sync, got %+v", delta)
+	}
+
+	sort.Strings(added)
+	if strings.Join(added, ",") != "one,two" {
+		t.Errorf("expected both tenants to be added, got %v", added)
+	}
+
+	if delta, _ := engine.Sync(); delta != nil {
+		t.Errorf("expected no delta when the system version is unchanged, got %+v", delta)
+	}
+
+	setOverview(source, 2, map[string]int64{"one": 2, "three": 1})
+
+	delta, err = engine.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delta.Full || delta.Changed["one"] != 2 || delta.Added["three"] != 1 || len(delta.Removed) != 1 || delta.Removed[0] != "two" {
+		t.Errorf("unexpected delta: %+v", delta)
+	}
+
+	if strings.Join(updated, ",") != "one" || strings.Join(removed, ",") != "two" || len(added) != 3 {
+		t.Errorf("unexpected callbacks: added %v, updated %v, removed %v", added, updated, removed)
+	}
+
+	tenants := registry.Tenants()
+	if len(tenants) != 2 || tenants["one"] != 2 || tenants["three"] != 1 || registry.SystemVersion() != 2 {
+		t.Errorf("unexpected registry contents: %v at version %d", tenants, registry.SystemVersion())
+	}
+
+	one, exists := registry.Tenant("one")
+	if !exists || len(one.Namespaces["default"].Workflows) != 1 || one.Namespaces["default"].Capabilities == nil {
+		t.Errorf("expected tenant one to be materialized, got %+v", one)
+	}
+
+	if _, exists := registry.Module("fqmn://one/default/mod@abc"); !exists {
+		t.Error("expected tenant one's module to be materialized")
+	}
+}
+
+func TestSyncEngineMaterializeFailure(t *testing.T) {
+	source := &overviewSource{}
+	setOverview(source, 1, map[string]int64{"one": 1})
+
+	registry := NewRegistry()
+	engine := NewSyncEngine(source, registry)
+
+	if _, err := engine.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	setOverview(source, 2, map[string]int64{"one": 2, "broken": 1})
+
+	// the broken tenant is skipped and reported, while the others advance.
+	delta, err := engine.Sync()
+
+	var materializeErr *MaterializeError
+	if !errors.As(err, &materializeErr) || len(materializeErr.Tenants) != 1 || materializeErr.Tenants["broken"] == nil {
+		t.Fatal("expected a MaterializeError for the broken tenant, got:", err)
+	}
+
+	if delta == nil || delta.SystemVersion != 2 {
+		t.Errorf("expected the applied delta to be returned, got %+v", delta)
+	}
+
+	if tenants := registry.Tenants(); registry.SystemVersion() != 2 || len(tenants) != 1 || tenants["one"] != 2 {
+		t.Errorf("expected only tenant one to advance, got %v at version %d", tenants, registry.SystemVersion())
+	}
+
+	// the failed tenant is retried even though the system version hasn't changed.
+	source.fixed = true
+
+	if _, err := engine.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tenants := registry.Tenants(); len(tenants) != 2 || tenants["broken"] != 1 {
+		t.Errorf("expected the broken tenant to be added once fixed, got %v", tenants)
+	}
+
+	if delta, err := engine.Sync(); delta != nil || err != nil {
+		t.Errorf("expected nothing left to retry, got %+v (%v)", delta, err)
+	}
+}
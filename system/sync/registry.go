@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/suborbital/systemspec/capabilities"
+	"github.com/suborbital/systemspec/system"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// Tenant is a tenant version that has been fully materialized from a Source.
+type Tenant struct {
+	Overview   *system.TenantOverview
+	Namespaces map[string]*Namespace
+
+	// Modules maps each of the tenant's module FQMNs to the module.
+	Modules map[string]*tenant.Module
+}
+
+// Version returns the tenant version that was materialized.
+func (t *Tenant) Version() int64 {
+	return t.Overview.Version
+}
+
+// Namespace is a namespace within a materialized Tenant.
+type Namespace struct {
+	Name           string
+	Workflows      []tenant.Workflow
+	Connections    []tenant.Connection
+	Authentication *tenant.Authentication
+	Capabilities   *capabilities.CapabilityConfig
+}
+
+// Registry is a local, thread-safe registry of the tenants in a system, materialized by a SyncEngine.
+type Registry struct {
+	systemVersion int64
+	tenants       map[string]*Tenant
+
+	lock sync.RWMutex
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	r := &Registry{
+		tenants: map[string]*Tenant{},
+		lock:    sync.RWMutex{},
+	}
+
+	return r
+}
+
+// SystemVersion returns the system version that the registry was last synced to.
+func (r *Registry) SystemVersion() int64 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.systemVersion
+}
+
+// Tenants returns a map of tenant idents to their materialized versions.
+func (r *Registry) Tenants() map[string]int64 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	tenants := make(map[string]int64, len(r.tenants))
+	for ident, t := range r.tenants {
+		tenants[ident] = t.Version()
+	}
+
+	return tenants
+}
+
+// Tenant returns the materialized tenant for the given ident, if it exists.
+// The returned Tenant is shared and must not be modified.
+func (r *Registry) Tenant(ident string) (*Tenant, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	t, exists := r.tenants[ident]
+
+	return t, exists
+}
+
+// Module returns the module with the given FQMN from any materialized tenant, if it exists.
+func (r *Registry) Module(FQMN string) (*tenant.Module, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, t := range r.tenants {
+		if mod, exists := t.Modules[FQMN]; exists {
+			return mod, true
+		}
+	}
+
+	return nil, false
+}
+
+// change describes the effect of applying a delta to a single tenant in the registry.
+type change struct {
+	ident    string
+	previous *Tenant
+	current  *Tenant
+}
+
+// apply updates the registry with the given delta, using [materialized] for each added or changed tenant,
+// and returns the changes that were made in the order they should be reported.
+func (r *Registry) apply(delta *system.OverviewDelta, materialized map[string]*Tenant) []change {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	removed := delta.Removed
+
+	if delta.Full {
+		removed = []string{}
+
+		for ident := range r.tenants {
+			if _, exists := materialized[ident]; !exists {
+				removed = append(removed, ident)
+			}
+		}
+
+		sort.Strings(removed)
+	}
+
+	changes := make([]change, 0, len(materialized)+len(removed))
+
+	idents := make([]string, 0, len(materialized))
+	for ident := range materialized {
+		idents = append(idents, ident)
+	}
+
+	sort.Strings(idents)
+
+	for _, ident := range idents {
+		previous, current := r.tenants[ident], materialized[ident]
+		if previous == current {
+			continue
+		}
+
+		changes = append(changes, change{ident: ident, previous: previous, current: current})
+		r.tenants[ident] = current
+	}
+
+	for _, ident := range removed {
+		previous, exists := r.tenants[ident]
+		if !exists {
+			continue
+		}
+
+		changes = append(changes, change{ident: ident, previous: previous})
+		delete(r.tenants, ident)
+	}
+
+	r.systemVersion = delta.SystemVersion
+
+	return changes
+}
@@ -0,0 +1,163 @@
+package system
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CredentialVerifier implementations authenticate Credentials presented by clients.
+type CredentialVerifier interface {
+	// Verify returns the Grant for the given Credential, or ErrAuthenticationFailed if it is not valid.
+	Verify(ctx context.Context, creds Credential) (*Grant, error)
+}
+
+// Grant describes a token known to a HashedTokenStore, identified by its hash, and what it allows access to.
+type Grant struct {
+	// Hash is the hex-encoded SHA-256 hash of the token, see TokenHash.
+	Hash string `json:"hash" yaml:"hash"`
+
+	// Scheme, if set, is the credential scheme the token must be presented with, such as "Bearer".
+	Scheme string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+
+	// Tenants are the tenant identifiers the token may access. If empty, every tenant may be accessed.
+	Tenants []string `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+
+	// ExpiresAt is the time after which the token is no longer valid. If zero, the token does not expire.
+	ExpiresAt time.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+
+	hash []byte
+}
+
+// GrantForToken returns a Grant allowing access to every tenant for the given plaintext token.
+func GrantForToken(token string) Grant {
+	return Grant{Hash: hex.EncodeToString(TokenHash(token))}
+}
+
+// AllowsTenant returns true if the grant allows access to the given tenant.
+func (g *Grant) AllowsTenant(ident string) bool {
+	if len(g.Tenants) == 0 {
+		return true
+	}
+
+	for _, t := range g.Tenants {
+		if t == ident {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expired returns true if the grant's expiry has passed.
+func (g *Grant) Expired() bool {
+	return !g.ExpiresAt.IsZero() && !time.Now().Before(g.ExpiresAt)
+}
+
+// HashedTokenStore is a CredentialVerifier that stores only the hashes of the tokens it accepts.
+type HashedTokenStore struct {
+	grants []Grant
+
+	lock sync.RWMutex
+}
+
+// NewHashedTokenStore creates a HashedTokenStore containing the given grants.
+func NewHashedTokenStore(grants ...Grant) (*HashedTokenStore, error) {
+	h := &HashedTokenStore{
+		lock: sync.RWMutex{},
+	}
+
+	for _, g := range grants {
+		if err := h.Add(g); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// LoadHashedTokenStore creates a HashedTokenStore from a JSON file containing a list of grants.
+func LoadHashedTokenStore(path string) (*HashedTokenStore, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to ReadFile")
+	}
+
+	grants := []Grant{}
+	if err := json.Unmarshal(contents, &grants); err != nil {
+		return nil, errors.Wrap(err, "failed to json.Unmarshal")
+	}
+
+	return NewHashedTokenStore(grants...)
+}
+
+// Add adds a grant to the store.
+func (h *HashedTokenStore) Add(grant Grant) error {
+	hash, err := hex.DecodeString(grant.Hash)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode grant hash")
+	}
+
+	grant.hash = hash
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.grants = append(h.grants, grant)
+
+	return nil
+}
+
+// Verify returns the Grant matching the given Credential's token. Every stored hash is compared in constant time
+// so that the time taken does not reveal which (if any) grant matched.
+func (h *HashedTokenStore) Verify(_ context.Context, creds Credential) (*Grant, error) {
+	if creds == nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	hash := TokenHash(creds.Value())
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	var match *Grant
+
+	for i := range h.grants {
+		if subtle.ConstantTimeCompare(hash, h.grants[i].hash) == 1 {
+			match = &h.grants[i]
+		}
+	}
+
+	if match == nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	if match.Scheme != "" && !strings.EqualFold(match.Scheme, creds.Scheme()) {
+		return nil, errors.Wrap(ErrAuthenticationFailed, "credential has the wrong scheme")
+	}
+
+	if match.Expired() {
+		return nil, errors.Wrap(ErrAuthenticationFailed, "credential has expired")
+	}
+
+	grant := *match
+
+	return &grant, nil
+}
+
+// CredentialFromHeader parses an Authorization header of the form "<scheme> <value>" into a Credential.
+func CredentialFromHeader(header string) (Credential, error) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errors.Wrap(ErrAuthenticationFailed, "authorization header is malformed")
+	}
+
+	return NewCredential(parts[0], strings.TrimSpace(parts[1])), nil
+}
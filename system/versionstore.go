@@ -0,0 +1,183 @@
+package system
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/suborbital/systemspec/fqmn"
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// LatestVersion can be passed as the version of a tenant to request its latest version.
+const LatestVersion int64 = 0
+
+// DefaultVersionHistory is the number of versions of each tenant kept by sources that don't configure their own.
+const DefaultVersionHistory = 5
+
+// VersionStore holds a bounded history of tenant configs so that in-flight work pinned
+// to a previous tenant version continues to resolve during a rollout.
+type VersionStore struct {
+	history int
+
+	// tenants maps each tenant identifier to its configs, sorted by ascending version.
+	tenants map[string][]*tenant.Config
+
+	lock sync.RWMutex
+}
+
+// NewVersionStore creates a VersionStore that keeps up to [history] versions of each tenant.
+// A history of zero or less keeps every version.
+func NewVersionStore(history int) *VersionStore {
+	v := &VersionStore{
+		history: history,
+		tenants: map[string][]*tenant.Config{},
+		lock:    sync.RWMutex{},
+	}
+
+	return v
+}
+
+// Add stores the given config, replacing any config with the same identifier and version
+// and evicting the oldest versions beyond the configured history.
+func (v *VersionStore) Add(config *tenant.Config) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	versions := v.tenants[config.Identifier]
+
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].TenantVersion >= config.TenantVersion
+	})
+
+	if i < len(versions) && versions[i].TenantVersion == config.TenantVersion {
+		versions[i] = config
+	} else {
+		versions = append(versions, nil)
+		copy(versions[i+1:], versions[i:])
+		versions[i] = config
+	}
+
+	if v.history > 0 && len(versions) > v.history {
+		// copy into a new slice so that evicted configs can be garbage collected.
+		versions = append([]*tenant.Config{}, versions[len(versions)-v.history:]...)
+	}
+
+	v.tenants[config.Identifier] = versions
+}
+
+// Remove removes every version of the given tenant.
+func (v *VersionStore) Remove(ident string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	delete(v.tenants, ident)
+}
+
+// Get returns the config for the exact requested tenant version, or ErrVersionNotFound if that version isn't stored.
+// A version of zero means the latest version, so that callers that predate version history (and always passed
+// zero) keep getting the current config. Any other version that isn't stored is an error, even if it's the
+// only version the caller has seen; sources that ignored the version before now reject mismatched requests.
+func (v *VersionStore) Get(ident string, version int64) (*tenant.Config, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	versions, exists := v.tenants[ident]
+	if !exists {
+		return nil, ErrTenantNotFound
+	}
+
+	if version == LatestVersion {
+		return versions[len(versions)-1], nil
+	}
+
+	for _, c := range versions {
+		if c.TenantVersion == version {
+			return c, nil
+		}
+	}
+
+	return nil, ErrVersionNotFound
+}
+
+// Latest returns the config for the highest stored version of the given tenant.
+func (v *VersionStore) Latest(ident string) (*tenant.Config, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	versions, exists := v.tenants[ident]
+	if !exists {
+		return nil, ErrTenantNotFound
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// Versions returns the stored versions of the given tenant in ascending order.
+func (v *VersionStore) Versions(ident string) []int64 {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	versions := make([]int64, len(v.tenants[ident]))
+	for i, c := range v.tenants[ident] {
+		versions[i] = c.TenantVersion
+	}
+
+	return versions
+}
+
+// Identifiers returns a map of every stored tenant identifier to its latest version.
+func (v *VersionStore) Identifiers() map[string]int64 {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	idents := make(map[string]int64, len(v.tenants))
+	for ident, versions := range v.tenants {
+		idents[ident] = versions[len(versions)-1].TenantVersion
+	}
+
+	return idents
+}
+
+// Namespace returns the requested namespace config for the requested tenant version, as resolved by Get.
+func (v *VersionStore) Namespace(ident, namespace string, version int64) (*tenant.NamespaceConfig, error) {
+	config, err := v.Get(ident, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace == fqmn.NamespaceDefault {
+		return &config.DefaultNamespace, nil
+	}
+
+	for i, n := range config.Namespaces {
+		if n.Name == namespace {
+			return &config.Namespaces[i], nil
+		}
+	}
+
+	return nil, ErrNamespaceNotFound
+}
+
+// GetModule searches every stored version of the FQMN's tenant for the requested module,
+// newest first, otherwise ErrModuleNotFound.
+func (v *VersionStore) GetModule(FQMN string) (*tenant.Module, error) {
+	f, err := fqmn.Parse(FQMN)
+	if err != nil {
+		return nil, ErrModuleNotFound
+	}
+
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	versions := v.tenants[f.Tenant]
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		for j, m := range versions[i].Modules {
+			if m.FQMN == FQMN {
+				return &versions[i].Modules[j], nil
+			}
+		}
+	}
+
+	return nil, ErrModuleNotFound
+}
@@ -0,0 +1,41 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func TestVersionStoreHistory(t *testing.T) {
+	store := NewVersionStore(2)
+
+	for _, v := range []int64{1, 3, 2} {
+		store.Add(&tenant.Config{Identifier: "com.suborbital.test", TenantVersion: v})
+	}
+
+	if versions := store.Versions("com.suborbital.test"); len(versions) != 2 || versions[0] != 2 || versions[1] != 3 {
+		t.Errorf("expected versions [2 3], got %v", versions)
+	}
+
+	if _, err := store.Get("com.suborbital.test", 1); !errors.Is(err, ErrVersionNotFound) {
+		t.Error("evicted version should return ErrVersionNotFound, got:", err)
+	}
+
+	if _, err := store.Get("com.suborbital.other", 1); !errors.Is(err, ErrTenantNotFound) {
+		t.Error("unknown tenant should return ErrTenantNotFound, got:", err)
+	}
+
+	if current, err := store.Get("com.suborbital.test", LatestVersion); err != nil || current.TenantVersion != 3 {
+		t.Errorf("version zero should return the latest version, got %v (%v)", current, err)
+	}
+
+	latest, err := store.Latest("com.suborbital.test")
+	if err != nil || latest.TenantVersion != 3 {
+		t.Errorf("expected latest version 3, got %v (%v)", latest, err)
+	}
+
+	if ident := store.Identifiers(); ident["com.suborbital.test"] != 3 {
+		t.Errorf("unexpected identifiers: %v", ident)
+	}
+}
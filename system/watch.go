@@ -0,0 +1,176 @@
+package system
+
+import (
+	"context"
+	"sync"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+// EventType describes the kind of change an Event represents.
+type EventType string
+
+// EventTenantAdded and others are the types of change events emitted by a WatchableSource.
+const (
+	EventTenantAdded          = EventType("tenantAdded")
+	EventTenantVersionUpdated = EventType("tenantVersionUpdated")
+	EventTenantRemoved        = EventType("tenantRemoved")
+	EventModuleAdded          = EventType("moduleAdded")
+	EventModuleRemoved        = EventType("moduleRemoved")
+
+	// EventSystemUpdated indicates that the system version changed without further detail,
+	// and is used when the specific changes cannot be determined or none of the other types apply.
+	EventSystemUpdated = EventType("systemUpdated")
+)
+
+// eventBufferSize is the number of events buffered for each watcher before events are dropped.
+const eventBufferSize = 64
+
+// Event describes a change to the system.
+type Event struct {
+	Type          EventType `json:"type"`
+	SystemVersion int64     `json:"systemVersion"`
+	Identifier    string    `json:"identifier,omitempty"`
+	TenantVersion int64     `json:"tenantVersion,omitempty"`
+	FQMN          string    `json:"fqmn,omitempty"`
+}
+
+// WatchableSource is a Source that can push change events to its clients rather than
+// requiring them to poll State.
+type WatchableSource interface {
+	Source
+
+	// Watch returns a channel of change events that is closed once ctx is cancelled. Events are dropped
+	// if the receiver falls behind, so receivers should compare SystemVersion to detect any gaps.
+	Watch(ctx context.Context) (<-chan Event, error)
+}
+
+// Broadcaster fans out events to every subscribed watcher, and can be used to implement WatchableSource.
+type Broadcaster struct {
+	watchers map[chan Event]struct{}
+
+	lock sync.Mutex
+}
+
+// NewBroadcaster creates a new Broadcaster with no watchers.
+func NewBroadcaster() *Broadcaster {
+	b := &Broadcaster{
+		watchers: map[chan Event]struct{}{},
+		lock:     sync.Mutex{},
+	}
+
+	return b
+}
+
+// Watch subscribes to the broadcaster until ctx is cancelled.
+func (b *Broadcaster) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event, eventBufferSize)
+
+	b.lock.Lock()
+	b.watchers[events] = struct{}{}
+	b.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		delete(b.watchers, events)
+		close(events)
+	}()
+
+	return events
+}
+
+// Publish sends the given events to every watcher, dropping them for any watcher whose buffer is full.
+func (b *Broadcaster) Publish(events ...Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for watcher := range b.watchers {
+		for _, e := range events {
+			select {
+			case watcher <- e:
+			default:
+			}
+		}
+	}
+}
+
+// ConfigEvents determines the events that describe the change from the previous to the next version
+// of a tenant's config. Previous may be nil if the tenant is new. A change that none of the specific
+// events describe, such as a workflow edited without bumping TenantVersion, produces EventSystemUpdated
+// so that every change results in at least one event.
+func ConfigEvents(systemVersion int64, previous, next *tenant.Config) []Event {
+	events := []Event{}
+
+	if previous == nil {
+		events = append(events, Event{
+			Type:          EventTenantAdded,
+			SystemVersion: systemVersion,
+			Identifier:    next.Identifier,
+			TenantVersion: next.TenantVersion,
+		})
+	} else if previous.TenantVersion != next.TenantVersion {
+		events = append(events, Event{
+			Type:          EventTenantVersionUpdated,
+			SystemVersion: systemVersion,
+			Identifier:    next.Identifier,
+			TenantVersion: next.TenantVersion,
+		})
+	}
+
+	existing := map[string]bool{}
+
+	if previous != nil {
+		for _, m := range previous.Modules {
+			existing[m.FQMN] = true
+		}
+	}
+
+	current := map[string]bool{}
+
+	for _, m := range next.Modules {
+		current[m.FQMN] = true
+
+		if existing[m.FQMN] {
+			continue
+		}
+
+		events = append(events, Event{
+			Type:          EventModuleAdded,
+			SystemVersion: systemVersion,
+			Identifier:    next.Identifier,
+			TenantVersion: next.TenantVersion,
+			FQMN:          m.FQMN,
+		})
+	}
+
+	if previous != nil {
+		for _, m := range previous.Modules {
+			if current[m.FQMN] {
+				continue
+			}
+
+			events = append(events, Event{
+				Type:          EventModuleRemoved,
+				SystemVersion: systemVersion,
+				Identifier:    next.Identifier,
+				TenantVersion: next.TenantVersion,
+				FQMN:          m.FQMN,
+			})
+		}
+	}
+
+	if len(events) == 0 {
+		events = append(events, Event{
+			Type:          EventSystemUpdated,
+			SystemVersion: systemVersion,
+			Identifier:    next.Identifier,
+			TenantVersion: next.TenantVersion,
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,32 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/suborbital/systemspec/tenant"
+)
+
+func TestConfigEvents(t *testing.T) {
+	previous := &tenant.Config{
+		Identifier:    "com.suborbital.test",
+		TenantVersion: 1,
+		Modules:       []tenant.Module{{FQMN: "fqmn://com.suborbital.test/default/hello@abc"}, {FQMN: "fqmn://com.suborbital.test/default/goodbye@abc"}},
+	}
+
+	next := &tenant.Config{
+		Identifier:    "com.suborbital.test",
+		TenantVersion: 1,
+		Modules:       []tenant.Module{{FQMN: "fqmn://com.suborbital.test/default/hello@abc"}},
+	}
+
+	events := ConfigEvents(2, previous, next)
+	if len(events) != 1 || events[0].Type != EventModuleRemoved || events[0].FQMN != "fqmn://com.suborbital.test/default/goodbye@abc" {
+		t.Errorf("expected a single moduleRemoved event, got %+v", events)
+	}
+
+	// a change that keeps the tenant version and modules, such as an edited workflow, still produces an event.
+	events = ConfigEvents(3, next, next)
+	if len(events) != 1 || events[0].Type != EventSystemUpdated || events[0].SystemVersion != 3 {
+		t.Errorf("expected a single systemUpdated event, got %+v", events)
+	}
+}
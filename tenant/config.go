@@ -2,10 +2,8 @@ package tenant
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 
 	"github.com/suborbital/systemspec/capabilities"
 	fqmn "github.com/suborbital/systemspec/fqmn"
@@ -33,6 +31,11 @@ type Config struct {
 }
 
 // NamespaceConfig is the configuration for a namespace.
+//
+// A workflow can only use the modules visible from its namespace. The default namespace is the root of the tenant,
+// so its workflows can use every module. Workflows in any other namespace can use the modules in their own namespace,
+// in the default namespace, and in the namespaces they are nested within: api/users can use the modules in api, but
+// api cannot use the modules in api/users.
 type NamespaceConfig struct {
 	Name           string                         `yaml:"name" json:"name"`
 	Workflows      []Workflow                     `yaml:"workflows,omitempty" json:"workflows,omitempty"`
@@ -53,9 +56,29 @@ type Workflow struct {
 	Triggers []Trigger      `yaml:"triggers" json:"triggers"`
 }
 
-// Schedule represents the schedule settings for a workflow.
+// Schedule represents the schedule settings for a workflow. A schedule runs either every fixed
+// interval or at the times described by a cron expression, see NextRuns.
 type Schedule struct {
-	Every ScheduleEvery     `yaml:"every" json:"every"`
+	Every ScheduleEvery `yaml:"every" json:"every"`
+
+	// Cron is a 5 or 6 field cron expression, such as "0 9 * * MON-FRI", see the cron package. As with Vixie cron,
+	// a run at a time that is skipped when the clocks go forward happens when they jump, and runs at times that are
+	// repeated when the clocks go back happen on each occurrence.
+	Cron string `yaml:"cron,omitempty" json:"cron,omitempty"`
+
+	// TimeZone is the IANA time zone that Cron is evaluated in, such as Europe/Berlin. It defaults to UTC.
+	TimeZone string `yaml:"timeZone,omitempty" json:"timeZone,omitempty"`
+
+	// Start and End are RFC 3339 timestamps limiting when the schedule runs. Either can be empty.
+	Start string `yaml:"start,omitempty" json:"start,omitempty"`
+	End   string `yaml:"end,omitempty" json:"end,omitempty"`
+
+	// Jitter is the maximum random delay added to each run, as a Go duration such as 30s.
+	Jitter string `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+
+	// Overlap is what happens when a run is due while the previous one is still going, defaulting to OverlapSkip.
+	Overlap OverlapPolicy `yaml:"overlap,omitempty" json:"overlap,omitempty"`
+
 	State map[string]string `yaml:"state,omitempty" json:"state,omitempty"`
 	Steps []WorkflowStep    `yaml:"steps" json:"steps"`
 }
@@ -120,9 +143,18 @@ func (c *Config) Marshal() ([]byte, error) {
 	return b, nil
 }
 
-// Unmarshal unmarshals JSON bytes into a TenantConfig struct
-// it also calculates a map of FQMNs for later use.
+// Unmarshal unmarshals JSON bytes into a TenantConfig struct, upgrading configs written with
+// an older spec version first. It also calculates a map of FQMNs for later use.
 func (c *Config) Unmarshal(in []byte) error {
+	if _, err := c.UnmarshalMigrate(in); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// unmarshal unmarshals JSON bytes that are already at CurrentSpecVersion into the Config.
+func (c *Config) unmarshal(in []byte) error {
 	if err := json.Unmarshal(in, c); err != nil {
 		return errors.Wrap(err, "json.Unmarshal")
 	}
@@ -132,15 +164,13 @@ func (c *Config) Unmarshal(in []byte) error {
 	return nil
 }
 
-// UnmarshalYaml unmarshals YAML bytes into a TenantConfig struct
-// it also calculates a map of FQMNs for later use.
+// UnmarshalYaml unmarshals YAML bytes into a TenantConfig struct, upgrading configs written with
+// an older spec version first. It also calculates a map of FQMNs for later use.
 func (c *Config) UnmarshalYaml(in []byte) error {
-	if err := yaml.Unmarshal(in, c); err != nil {
-		return errors.Wrap(err, "yaml.Unmarshal")
+	if _, err := c.UnmarshalYamlMigrate(in); err != nil {
+		return err
 	}
 
-	c.calculateFQMNs()
-
 	return nil
 }
 
@@ -169,33 +199,3 @@ func (c *Config) FQMNForFunc(namespace, fn, ref string) (string, error) {
 
 	return fqmnString, nil
 }
-
-// NumberOfSeconds calculates the total time in seconds for the schedule's 'every' value.
-func (s *Schedule) NumberOfSeconds() int {
-	seconds := s.Every.Seconds
-	minutes := 60 * s.Every.Minutes
-	hours := 60 * 60 * s.Every.Hours
-	days := 60 * 60 * 24 * s.Every.Days
-
-	return seconds + minutes + hours + days
-}
-
-type problems []error
-
-func (p *problems) add(err error) {
-	*p = append(*p, err)
-}
-
-func (p *problems) render() error {
-	if len(*p) == 0 {
-		return nil
-	}
-
-	text := fmt.Sprintf("found %d problems:", len(*p))
-
-	for _, err := range *p {
-		text += fmt.Sprintf("\n\t%s", err.Error())
-	}
-
-	return errors.New(text)
-}
@@ -1,8 +1,11 @@
 package tenant
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/suborbital/systemspec/capabilities"
 )
 
 func TestYAMLMarshalUnmarshal(t *testing.T) {
@@ -246,3 +249,147 @@ func TestConfigFQMNs(t *testing.T) {
 		t.Error("should not have found a Module for foo::bar")
 	}
 }
+
+func TestConfigValidatorNamespaces(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			Identifier:    "dev.suborbital.appname",
+			TenantVersion: 1,
+			Namespaces: []NamespaceConfig{
+				{
+					Name: "api",
+					Workflows: []Workflow{
+						{
+							Name:  "getUser",
+							Steps: []WorkflowStep{{FQMN: "/name/api/returnUser"}, {FQMN: "/name/default/log"}},
+						},
+					},
+				},
+				{
+					Name: "api/users",
+					Workflows: []Workflow{
+						{
+							Name:  "addUser",
+							Steps: []WorkflowStep{{FQMN: "/name/api/users/addUser"}, {FQMN: "/name/api/returnUser"}},
+						},
+					},
+				},
+				{Name: "db"},
+			},
+			Modules: []Module{
+				{Name: "log", Namespace: "default"},
+				{Name: "returnUser", Namespace: "api"},
+				{Name: "addUser", Namespace: "api/users"},
+				{Name: "getUser", Namespace: "db"},
+			},
+		}
+	}
+
+	conf := valid()
+	if err := conf.Validate(); err != nil {
+		t.Fatal("expected valid namespaced config, got:", err)
+	}
+
+	tests := map[string]func(c *Config){
+		"duplicate namespace": func(c *Config) {
+			c.Namespaces = append(c.Namespaces, NamespaceConfig{Name: "db"})
+		},
+		"reserved namespace": func(c *Config) {
+			c.Namespaces = append(c.Namespaces, NamespaceConfig{Name: "default"})
+		},
+		"bad connection": func(c *Config) {
+			c.Namespaces[2].Connections = []Connection{{Type: "carrier-pigeon"}}
+		},
+		"missing module": func(c *Config) {
+			c.Namespaces[0].Workflows[0].Steps[0].FQMN = "/name/api/missing"
+		},
+		"module not visible": func(c *Config) {
+			c.Namespaces[0].Workflows[0].Steps[0].FQMN = "/name/db/getUser"
+		},
+		"child module not visible from parent": func(c *Config) {
+			c.Namespaces[0].Workflows[0].Steps[0].FQMN = "/name/api/users/addUser"
+		},
+	}
+
+	for name, breakConfig := range tests {
+		conf := valid()
+		breakConfig(&conf)
+
+		if err := conf.Validate(); err == nil {
+			t.Errorf("%s: expected validation to fail", name)
+		}
+	}
+
+	// a module in an undeclared namespace is allowed, but warned about.
+	conf = valid()
+	conf.Modules = append(conf.Modules, Module{Name: "other", Namespace: "missing"})
+
+	if err := conf.Validate(); err != nil {
+		t.Error("expected an undeclared module namespace to be valid, got:", err)
+	}
+
+	if issues := conf.Issues(); len(issues) != 1 || issues[0].Code != IssueUndeclaredNamespace || issues[0].Severity != SeverityWarning {
+		t.Errorf("expected an undeclared namespace warning, got %v", issues)
+	}
+}
+
+func TestConfigValidationError(t *testing.T) {
+	conf := Config{
+		Identifier:    "dev.suborbital.appname",
+		TenantVersion: 1,
+		Namespaces: []NamespaceConfig{
+			{Name: "api"},
+			{
+				Name: "db",
+				Workflows: []Workflow{
+					{
+						Name:  "getUser",
+						Steps: []WorkflowStep{{FQMN: "/name/db/getUser"}, {Group: []string{"/name/db/getUser", "/name/db/missing"}}},
+					},
+				},
+				Authentication: &Authentication{
+					Domains: map[string]capabilities.AuthHeader{"example.com/api": {Value: "token"}},
+				},
+			},
+		},
+		Modules: []Module{
+			{Name: "getUser", Namespace: "db"},
+		},
+	}
+
+	issues := conf.Issues()
+
+	expected := []Issue{
+		{Path: "/namespaces/1/authentication/domains/example.com~1api/headerType", Severity: SeverityWarning, Code: IssueDefaultHeaderType},
+		{Path: "/namespaces/1/workflows/0/steps/1/group/1", Severity: SeverityError, Code: IssueModuleNotFound},
+		{Path: "/namespaces/1/workflows/0/response", Severity: SeverityError, Code: IssueMissingResponse},
+	}
+
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), issues)
+	}
+
+	for i, issue := range issues {
+		if issue.Path != expected[i].Path || issue.Severity != expected[i].Severity || issue.Code != expected[i].Code {
+			t.Errorf("expected issue %d to be %+v, got %+v", i, expected[i], issue)
+		}
+	}
+
+	err := conf.Validate()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Issues) != len(expected) {
+		t.Fatalf("expected a ValidationError with every issue, got %v", err)
+	}
+
+	// warnings alone do not make the config invalid.
+	conf.Namespaces[1].Workflows[0].Steps = conf.Namespaces[1].Workflows[0].Steps[:1]
+
+	if err := conf.Validate(); err != nil {
+		t.Error("expected a config with only warnings to be valid, got:", err)
+	}
+
+	if issues := conf.Issues(); len(issues) != 1 || issues[0].Severity != SeverityWarning {
+		t.Errorf("expected a single warning, got %v", issues)
+	}
+}
@@ -0,0 +1,362 @@
+package tenant
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/suborbital/systemspec/fqmn"
+)
+
+// CurrentSpecVersion is the SpecVersion of the tenant config format described by Config. Every older
+// version has a migration to the next one, so that any supported config can be upgraded to this one.
+const CurrentSpecVersion = 2
+
+// ErrUnknownSpecVersion is returned when a config's SpecVersion is newer than CurrentSpecVersion, or has no migration path.
+var ErrUnknownSpecVersion = errors.New("unknown tenant config spec version")
+
+// migration upgrades a tenant config document from one spec version to the next.
+type migration struct {
+	// From is the spec version that the migration upgrades from, to From+1.
+	From int
+
+	// Description summarises the migration.
+	Description string
+
+	// Migrate modifies the decoded JSON document in place, returning a description of each change it made.
+	// The document's specVersion is updated by the caller.
+	Migrate func(doc map[string]any) ([]Change, error)
+}
+
+// Change describes a single modification made by a migration.
+type Change struct {
+	// Path is a JSON pointer to the changed field, such as /defaultNamespace/workflows/0/steps/1/fqmn.
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+// MigrationReport describes the migrations applied to a tenant config document.
+type MigrationReport struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+
+	// Applied holds the Description of each migration applied, in order.
+	Applied []string `json:"applied"`
+	Changes []Change `json:"changes"`
+}
+
+// Migrated returns true if the document was upgraded from an older spec version.
+func (m *MigrationReport) Migrated() bool {
+	return m.From != m.To
+}
+
+// migrations holds the migration from each older spec version, keyed by the version it upgrades from.
+var migrations = map[int]migration{
+	1: {From: 1, Description: "rewrite namespace::name step references as FQMNs", Migrate: migrateV1ToV2},
+}
+
+// Migrate upgrades a JSON tenant config document step by step to CurrentSpecVersion and returns the upgraded
+// document along with a report of what changed. Documents without a specVersion are treated as version 1.
+// ErrUnknownSpecVersion is returned for versions newer than CurrentSpecVersion.
+func Migrate(in []byte) ([]byte, *MigrationReport, error) {
+	decoder := json.NewDecoder(bytes.NewReader(in))
+	decoder.UseNumber()
+
+	doc := map[string]any{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to Decode")
+	}
+
+	report, err := migrate(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !report.Migrated() {
+		return in, report, nil
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to json.Marshal")
+	}
+
+	return out, report, nil
+}
+
+// MigrateYaml is Migrate for a YAML tenant config document.
+func MigrateYaml(in []byte) ([]byte, *MigrationReport, error) {
+	var decoded any
+	if err := yaml.Unmarshal(in, &decoded); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to yaml.Unmarshal")
+	}
+
+	doc, ok := stringKeys(decoded).(map[string]any)
+	if !ok {
+		// leave anything that isn't a document for Unmarshal to reject.
+		return in, &MigrationReport{From: CurrentSpecVersion, To: CurrentSpecVersion, Applied: []string{}, Changes: []Change{}}, nil
+	}
+
+	report, err := migrate(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !report.Migrated() {
+		return in, report, nil
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to yaml.Marshal")
+	}
+
+	return out, report, nil
+}
+
+// migrate upgrades the decoded document in place to CurrentSpecVersion.
+func migrate(doc map[string]any) (*MigrationReport, error) {
+	version, err := specVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{From: version, To: version, Applied: []string{}, Changes: []Change{}}
+
+	if version > CurrentSpecVersion {
+		return nil, errors.Wrapf(ErrUnknownSpecVersion, "spec version %d is newer than the supported version %d", version, CurrentSpecVersion)
+	}
+
+	if version == CurrentSpecVersion {
+		return report, nil
+	}
+
+	for ; version < CurrentSpecVersion; version++ {
+		m, exists := migrations[version]
+		if !exists {
+			return nil, errors.Wrapf(ErrUnknownSpecVersion, "no migration from spec version %d", version)
+		}
+
+		changes, err := m.Migrate(doc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to migrate from spec version %d", version)
+		}
+
+		report.Applied = append(report.Applied, m.Description)
+		report.Changes = append(report.Changes, changes...)
+	}
+
+	doc["specVersion"] = CurrentSpecVersion
+	report.Changes = append(report.Changes, Change{Path: pointer("specVersion"), Description: fmt.Sprintf("set spec version to %d", CurrentSpecVersion)})
+	report.To = CurrentSpecVersion
+
+	return report, nil
+}
+
+// UnmarshalMigrate upgrades the JSON tenant config document to CurrentSpecVersion and unmarshals it into the Config.
+func (c *Config) UnmarshalMigrate(in []byte) (*MigrationReport, error) {
+	migrated, report, err := Migrate(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Migrate")
+	}
+
+	if err := c.unmarshal(migrated); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// UnmarshalYamlMigrate upgrades the YAML tenant config document to CurrentSpecVersion and unmarshals it into the Config.
+func (c *Config) UnmarshalYamlMigrate(in []byte) (*MigrationReport, error) {
+	migrated, report, err := MigrateYaml(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to MigrateYaml")
+	}
+
+	if err := yaml.Unmarshal(migrated, c); err != nil {
+		return nil, errors.Wrap(err, "yaml.Unmarshal")
+	}
+
+	c.calculateFQMNs()
+
+	return report, nil
+}
+
+// specVersion returns the document's spec version, treating a missing or zero version as 1.
+func specVersion(doc map[string]any) (int, error) {
+	raw, exists := doc["specVersion"]
+	if !exists || raw == nil {
+		return 1, nil
+	}
+
+	var version int
+
+	switch number := raw.(type) {
+	case json.Number:
+		parsed, err := strconv.Atoi(number.String())
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to parse specVersion")
+		}
+
+		version = parsed
+	case int:
+		version = number
+	default:
+		return 0, fmt.Errorf("specVersion must be a number, got %v", raw)
+	}
+
+	if version == 0 {
+		return 1, nil
+	}
+
+	return version, nil
+}
+
+// stringKeys converts the map[any]any values decoded by yaml.v2 into the map[string]any values used by migrations.
+func stringKeys(v any) any {
+	switch value := v.(type) {
+	case map[any]any:
+		converted := make(map[string]any, len(value))
+		for k, elem := range value {
+			converted[fmt.Sprint(k)] = stringKeys(elem)
+		}
+
+		return converted
+	case []any:
+		for i, elem := range value {
+			value[i] = stringKeys(elem)
+		}
+
+		return value
+	}
+
+	return v
+}
+
+// migrateV1ToV2 rewrites the v1 "fn" step field as "fqmn", and rewrites step references in the v1
+// [tenant#][namespace::]name[@version] format as FQMNs. References that are already FQMNs are left as-is.
+func migrateV1ToV2(doc map[string]any) ([]Change, error) {
+	changes := []Change{}
+
+	migrateSteps := func(path string, steps any) error {
+		list, ok := steps.([]any)
+		if !ok {
+			return nil
+		}
+
+		for i, s := range list {
+			step, ok := s.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			stepPath := path + pointer(i)
+
+			if fn, exists := step["fn"]; exists {
+				if _, hasFQMN := step["fqmn"]; !hasFQMN {
+					step["fqmn"] = fn
+				}
+
+				delete(step, "fn")
+				changes = append(changes, Change{Path: stepPath + pointer("fn"), Description: "renamed fn to fqmn"})
+			}
+
+			if ref, ok := step["fqmn"].(string); ok {
+				migrated, err := migrateReference(ref)
+				if err != nil {
+					return errors.Wrapf(err, "failed to migrate step %s", stepPath)
+				}
+
+				if migrated != ref {
+					step["fqmn"] = migrated
+					changes = append(changes, Change{Path: stepPath + pointer("fqmn"), Description: fmt.Sprintf("rewrote %s as %s", ref, migrated)})
+				}
+			}
+
+			group, _ := step["group"].([]any)
+			for j, g := range group {
+				ref, ok := g.(string)
+				if !ok {
+					continue
+				}
+
+				migrated, err := migrateReference(ref)
+				if err != nil {
+					return errors.Wrapf(err, "failed to migrate step %s", stepPath)
+				}
+
+				if migrated != ref {
+					group[j] = migrated
+					changes = append(changes, Change{Path: stepPath + pointer("group", j), Description: fmt.Sprintf("rewrote %s as %s", ref, migrated)})
+				}
+			}
+		}
+
+		return nil
+	}
+
+	migrateNamespace := func(path string, ns any) error {
+		namespace, ok := ns.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		workflows, _ := namespace["workflows"].([]any)
+		for i, w := range workflows {
+			workflow, ok := w.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			workflowPath := path + pointer("workflows", i)
+
+			if err := migrateSteps(workflowPath+pointer("steps"), workflow["steps"]); err != nil {
+				return err
+			}
+
+			if schedule, ok := workflow["schedule"].(map[string]any); ok {
+				if err := migrateSteps(workflowPath+pointer("schedule", "steps"), schedule["steps"]); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := migrateNamespace(pointer("defaultNamespace"), doc["defaultNamespace"]); err != nil {
+		return nil, err
+	}
+
+	namespaces, _ := doc["namespaces"].([]any)
+	for i, ns := range namespaces {
+		if err := migrateNamespace(pointer("namespaces", i), ns); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// migrateReference converts a v1 module reference into an FQMN, or returns it unchanged if it already is one.
+func migrateReference(ref string) (string, error) {
+	if _, err := fqmn.Parse(ref); err == nil || ref == "" {
+		return ref, nil
+	}
+
+	f, err := fqmn.MigrateV1ToV2(ref, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to MigrateV1ToV2")
+	}
+
+	if f.Tenant != "" {
+		return fmt.Sprintf("fqmn://%s/%s/%s", f.Tenant, f.Namespace, f.Name), nil
+	}
+
+	return fmt.Sprintf("/name/%s/%s", f.Namespace, f.Name), nil
+}
@@ -0,0 +1,146 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMigrateV1(t *testing.T) {
+	v1 := []byte(`{
+		"identifier": "dev.suborbital.appname",
+		"specVersion": 1,
+		"modules": [{"name": "getUser", "namespace": "db"}, {"name": "returnUser", "namespace": "default"}],
+		"namespaces": [{"name": "db"}],
+		"defaultNamespace": {
+			"workflows": [{
+				"name": "getUser",
+				"steps": [
+					{"group": ["db::getUser", "/name/db/getUser"]},
+					{"fn": "returnUser@v0.1.0"}
+				],
+				"response": "returnUser"
+			}]
+		}
+	}`)
+
+	conf := &Config{}
+
+	report, err := conf.UnmarshalMigrate(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Migrated() || report.From != 1 || report.To != CurrentSpecVersion || conf.SpecVersion != CurrentSpecVersion {
+		t.Errorf("unexpected report %+v for spec version %d", report, conf.SpecVersion)
+	}
+
+	steps := conf.DefaultNamespace.Workflows[0].Steps
+	if steps[0].Group[0] != "/name/db/getUser" || steps[0].Group[1] != "/name/db/getUser" || steps[1].FQMN != "/name/default/returnUser" {
+		t.Errorf("unexpected migrated steps %+v", steps)
+	}
+
+	paths := map[string]bool{}
+	for _, change := range report.Changes {
+		paths[change.Path] = true
+	}
+
+	for _, path := range []string{"/defaultNamespace/workflows/0/steps/0/group/0", "/defaultNamespace/workflows/0/steps/1/fn", "/defaultNamespace/workflows/0/steps/1/fqmn", "/specVersion"} {
+		if !paths[path] {
+			t.Errorf("expected a change at %s, got %+v", path, report.Changes)
+		}
+	}
+
+	if len(report.Applied) != 1 || report.Applied[0] != migrations[1].Description {
+		t.Errorf("expected the applied migration to be reported, got %+v", report.Applied)
+	}
+
+	if len(report.Changes) != 4 {
+		t.Errorf("expected 4 changes, got %+v", report.Changes)
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Error("expected the migrated config to be valid, got:", err)
+	}
+}
+
+func TestMigrateVersions(t *testing.T) {
+	current := []byte(`{"identifier": "dev.suborbital.appname", "specVersion": 2}`)
+
+	out, report, err := Migrate(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Migrated() || string(out) != string(current) {
+		t.Errorf("expected a current document to be unchanged, got %s", out)
+	}
+
+	if _, _, err := Migrate([]byte(`{"specVersion": 99}`)); !errors.Is(err, ErrUnknownSpecVersion) {
+		t.Error("expected ErrUnknownSpecVersion for a future spec version, got:", err)
+	}
+}
+
+func TestMigrateYaml(t *testing.T) {
+	v1 := []byte(`
+identifier: dev.suborbital.appname
+modules:
+  - name: getUser
+    namespace: db
+  - name: returnUser
+    namespace: default
+namespaces:
+  - name: db
+defaultNamespace:
+  workflows:
+    - name: getUser
+      steps:
+        - fn: db::getUser
+        - fn: returnUser
+      response: returnUser
+`)
+
+	conf := &Config{}
+	if err := conf.UnmarshalYaml(v1); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := conf.DefaultNamespace.Workflows[0].Steps
+	if conf.SpecVersion != CurrentSpecVersion || steps[0].FQMN != "/name/db/getUser" || steps[1].FQMN != "/name/default/returnUser" {
+		t.Errorf("expected the YAML config to be migrated, got spec version %d and steps %+v", conf.SpecVersion, steps)
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Error("expected the migrated config to be valid, got:", err)
+	}
+}
+
+func TestUnmarshalMigrates(t *testing.T) {
+	v1 := []byte(`{
+		"identifier": "dev.suborbital.appname",
+		"modules": [{"name": "getUser", "namespace": "db"}],
+		"namespaces": [{"name": "db"}],
+		"defaultNamespace": {"workflows": [{"name": "getUser", "steps": [{"fn": "db::getUser"}]}]}
+	}`)
+
+	conf := &Config{}
+	if err := conf.Unmarshal(v1); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := conf.DefaultNamespace.Workflows[0].Steps
+	if conf.SpecVersion != CurrentSpecVersion || steps[0].FQMN != "/name/db/getUser" {
+		t.Errorf("expected the JSON config to be migrated, got spec version %d and steps %+v", conf.SpecVersion, steps)
+	}
+
+	if err := conf.Unmarshal([]byte(`{"specVersion": 99}`)); !errors.Is(err, ErrUnknownSpecVersion) {
+		t.Error("expected ErrUnknownSpecVersion for a future spec version, got:", err)
+	}
+}
+
+func TestMigrationsComplete(t *testing.T) {
+	for version := 1; version < CurrentSpecVersion; version++ {
+		if m, exists := migrations[version]; !exists || m.From != version || m.Migrate == nil || m.Description == "" {
+			t.Errorf("expected a described migration from spec version %d", version)
+		}
+	}
+}
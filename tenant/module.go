@@ -11,6 +11,11 @@ type Module struct {
 	FQMN       string           `yaml:"fqmn,omitempty" json:"fqmn,omitempty"`
 	Revisions  []ModuleRevision `yaml:"revisions" json:"revisions"`
 	WasmRef    *WasmModuleRef   `yaml:"-" json:"wasmRef,omitempty"`
+
+	// Inputs lists the state keys that the module reads, and Outputs lists the state keys it writes in addition
+	// to its result. They are optional, and are used to check the flow of state through workflows.
+	Inputs  []string `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	Outputs []string `yaml:"outputs,omitempty" json:"outputs,omitempty"`
 }
 
 // WasmModuleRef is a reference to a Wasm module
@@ -0,0 +1,198 @@
+package tenant
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/cron"
+)
+
+// ErrInvalidSchedule is returned when a schedule's settings can't be used to compute its runs.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// OverlapPolicy describes what happens when a scheduled run is due while the previous run is still going.
+type OverlapPolicy string
+
+// OverlapSkip and others are the policies a schedule can use.
+const (
+	// OverlapSkip drops the run that is due. This is the default.
+	OverlapSkip = OverlapPolicy("skip")
+	// OverlapQueue starts the run that is due as soon as the previous run completes. At most one run is queued,
+	// so further runs that become due while the previous one is still running are skipped.
+	OverlapQueue = OverlapPolicy("queue")
+)
+
+// NumberOfSeconds calculates the total time in seconds for the schedule's 'every' value.
+func (s *Schedule) NumberOfSeconds() int {
+	seconds := s.Every.Seconds
+	minutes := 60 * s.Every.Minutes
+	hours := 60 * 60 * s.Every.Hours
+	days := 60 * 60 * 24 * s.Every.Days
+
+	return seconds + minutes + hours + days
+}
+
+// Location returns the time zone that the schedule's cron expression is evaluated in.
+func (s *Schedule) Location() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSchedule, "unknown time zone %s", s.TimeZone)
+	}
+
+	return loc, nil
+}
+
+// Window returns the times between which the schedule runs. Either is the zero time if it isn't set.
+func (s *Schedule) Window() (start, end time.Time, err error) {
+	if s.Start != "" {
+		if start, err = time.Parse(time.RFC3339, s.Start); err != nil {
+			return time.Time{}, time.Time{}, errors.Wrapf(ErrInvalidSchedule, "start %q is not an RFC 3339 timestamp", s.Start)
+		}
+	}
+
+	if s.End != "" {
+		if end, err = time.Parse(time.RFC3339, s.End); err != nil {
+			return time.Time{}, time.Time{}, errors.Wrapf(ErrInvalidSchedule, "end %q is not an RFC 3339 timestamp", s.End)
+		}
+	}
+
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return time.Time{}, time.Time{}, errors.Wrap(ErrInvalidSchedule, "end is not after start")
+	}
+
+	return start, end, nil
+}
+
+// JitterDuration returns the maximum random delay to add to each run, or 0 if there is none. The schedule must have been validated.
+func (s *Schedule) JitterDuration() time.Duration {
+	jitter, _ := time.ParseDuration(s.Jitter)
+
+	return jitter
+}
+
+// OverlapPolicy returns the schedule's overlap policy, which is OverlapSkip unless Overlap says otherwise.
+func (s *Schedule) OverlapPolicy() OverlapPolicy {
+	if s.Overlap == "" {
+		return OverlapSkip
+	}
+
+	return s.Overlap
+}
+
+// NextRuns returns up to n of the times that the schedule runs after from, in the schedule's time zone.
+// Fewer are returned if the schedule ends first. An interval schedule with a Start runs at Start and every
+// interval after it, otherwise it runs every interval after from. The times don't include jitter, which
+// callers should add to each run, see JitterDuration.
+func (s *Schedule) NextRuns(from time.Time, n int) ([]time.Time, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := s.Window()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(s.NumberOfSeconds()) * time.Second
+
+	var next func(t time.Time) time.Time
+
+	switch {
+	case s.Cron != "" && interval > 0:
+		return nil, errors.Wrap(ErrInvalidSchedule, "schedule has both 'every' values and a cron expression")
+	case s.Cron != "":
+		expr, err := cron.Parse(s.Cron)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to cron.Parse")
+		}
+
+		next = expr.Next
+	case interval > 0:
+		anchor := from
+		if !start.IsZero() {
+			anchor = start.Add(-interval)
+		}
+
+		next = func(t time.Time) time.Time {
+			if t.Before(anchor) {
+				return anchor.Add(interval)
+			}
+
+			return t.Add(interval - t.Sub(anchor)%interval)
+		}
+	default:
+		return nil, errors.Wrap(ErrInvalidSchedule, "schedule has no 'every' values or cron expression")
+	}
+
+	// a run exactly at the start of the window counts as being after from.
+	t := from.In(loc)
+	if !start.IsZero() && t.Before(start) {
+		t = start.In(loc).Add(-time.Nanosecond)
+	}
+
+	runs := []time.Time{}
+
+	for len(runs) < n {
+		t = next(t)
+
+		if t.IsZero() || (!end.IsZero() && t.After(end)) {
+			break
+		}
+
+		runs = append(runs, t.In(loc))
+	}
+
+	return runs, nil
+}
+
+// validateSchedule adds the issues found in the schedule of the workflow at [path] to problems.
+func validateSchedule(path string, w Workflow, problems *problems) {
+	s := w.Schedule
+
+	hasEvery := s.Every.Seconds != 0 || s.Every.Minutes != 0 || s.Every.Hours != 0 || s.Every.Days != 0
+
+	switch {
+	case !hasEvery && s.Cron == "":
+		problems.add(path+pointer("every"), IssueEmptySchedule, fmt.Errorf("workflow %s's schedule has no 'every' values or cron expression", w.Name))
+	case hasEvery && s.Cron != "":
+		problems.add(path+pointer("cron"), IssueInvalidSchedule, fmt.Errorf("workflow %s's schedule has both 'every' values and a cron expression", w.Name))
+	case hasEvery && s.NumberOfSeconds() <= 0:
+		problems.add(path+pointer("every"), IssueInvalidSchedule, fmt.Errorf("workflow %s's schedule has an 'every' interval that isn't positive", w.Name))
+	case s.Cron != "":
+		if _, err := cron.Parse(s.Cron); err != nil {
+			problems.add(path+pointer("cron"), IssueInvalidSchedule, fmt.Errorf("workflow %s's schedule has an invalid cron expression: %s", w.Name, err.Error()))
+		}
+	}
+
+	if _, err := s.Location(); err != nil {
+		problems.add(path+pointer("timeZone"), IssueInvalidSchedule, fmt.Errorf("workflow %s's schedule has an unknown time zone %s", w.Name, s.TimeZone))
+	}
+
+	if _, _, err := s.Window(); err != nil {
+		windowPath := path + pointer("end")
+		if _, err := time.Parse(time.RFC3339, s.Start); s.Start != "" && err != nil {
+			windowPath = path + pointer("start")
+		}
+
+		problems.add(windowPath, IssueInvalidSchedule, fmt.Errorf("workflow %s's schedule has an invalid window: %s", w.Name, err.Error()))
+	}
+
+	if s.Jitter != "" {
+		if jitter, err := time.ParseDuration(s.Jitter); err != nil || jitter < 0 {
+			problems.add(path+pointer("jitter"), IssueInvalidDuration, fmt.Errorf("workflow %s's schedule has an invalid jitter: %q is not a duration", w.Name, s.Jitter))
+		}
+	}
+
+	switch s.Overlap {
+	case "", OverlapSkip, OverlapQueue:
+	default:
+		problems.add(path+pointer("overlap"), IssueUnknownOverlapPolicy, fmt.Errorf("workflow %s's schedule has unknown overlap policy %s", w.Name, s.Overlap))
+	}
+}
@@ -0,0 +1,171 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleNextRuns(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+
+	// Friday 2023-03-24 12:00 UTC.
+	from := time.Date(2023, 3, 24, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		schedule Schedule
+		n        int
+		expected []time.Time
+	}{
+		"9am Berlin on weekdays": {
+			schedule: Schedule{Cron: "0 9 * * MON-FRI", TimeZone: "Europe/Berlin"},
+			n:        3,
+			expected: []time.Time{
+				time.Date(2023, 3, 27, 9, 0, 0, 0, berlin),
+				time.Date(2023, 3, 28, 9, 0, 0, 0, berlin),
+				time.Date(2023, 3, 29, 9, 0, 0, 0, berlin),
+			},
+		},
+		"cron within a window": {
+			schedule: Schedule{Cron: "@daily", Start: "2023-04-01T00:00:00Z", End: "2023-04-02T23:00:00Z"},
+			n:        5,
+			expected: []time.Time{
+				time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 4, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		"every interval": {
+			schedule: Schedule{Every: ScheduleEvery{Minutes: 30}},
+			n:        2,
+			expected: []time.Time{
+				time.Date(2023, 3, 24, 12, 30, 0, 0, time.UTC),
+				time.Date(2023, 3, 24, 13, 0, 0, 0, time.UTC),
+			},
+		},
+		"every interval from a past start": {
+			schedule: Schedule{Every: ScheduleEvery{Hours: 1}, Start: "2023-03-24T08:20:00Z"},
+			n:        2,
+			expected: []time.Time{
+				time.Date(2023, 3, 24, 12, 20, 0, 0, time.UTC),
+				time.Date(2023, 3, 24, 13, 20, 0, 0, time.UTC),
+			},
+		},
+		"every interval from a future start": {
+			schedule: Schedule{Every: ScheduleEvery{Days: 1}, Start: "2023-03-30T06:00:00Z"},
+			n:        2,
+			expected: []time.Time{
+				time.Date(2023, 3, 30, 6, 0, 0, 0, time.UTC),
+				time.Date(2023, 3, 31, 6, 0, 0, 0, time.UTC),
+			},
+		},
+		"ended": {
+			schedule: Schedule{Cron: "@hourly", End: "2023-03-24T11:00:00Z"},
+			n:        2,
+			expected: []time.Time{},
+		},
+	}
+
+	for name, tc := range tests {
+		runs, err := tc.schedule.NextRuns(from, tc.n)
+		if err != nil {
+			t.Errorf("%s: failed to NextRuns: %s", name, err)
+			continue
+		}
+
+		if len(runs) != len(tc.expected) {
+			t.Errorf("%s: expected %d runs, got %v", name, len(tc.expected), runs)
+			continue
+		}
+
+		for i := range runs {
+			if !runs[i].Equal(tc.expected[i]) {
+				t.Errorf("%s: expected run %d to be %s, got %s", name, i, tc.expected[i], runs[i])
+			}
+		}
+	}
+
+	runs, _ := (&Schedule{Cron: "0 9 * * *", TimeZone: "Europe/Berlin"}).NextRuns(from, 1)
+	if len(runs) != 1 || runs[0].Location().String() != "Europe/Berlin" {
+		t.Error("expected runs in the schedule's time zone, got", runs)
+	}
+
+	if _, err := (&Schedule{}).NextRuns(from, 1); !errors.Is(err, ErrInvalidSchedule) {
+		t.Error("expected ErrInvalidSchedule, got:", err)
+	}
+}
+
+func TestScheduleValidation(t *testing.T) {
+	tests := map[string]struct {
+		schedule Schedule
+		path     string
+		code     IssueCode
+	}{
+		"empty": {
+			schedule: Schedule{},
+			path:     "/defaultNamespace/workflows/0/schedule/every",
+			code:     IssueEmptySchedule,
+		},
+		"every and cron": {
+			schedule: Schedule{Every: ScheduleEvery{Minutes: 1}, Cron: "* * * * *"},
+			path:     "/defaultNamespace/workflows/0/schedule/cron",
+			code:     IssueInvalidSchedule,
+		},
+		"invalid cron": {
+			schedule: Schedule{Cron: "0 25 * * *"},
+			path:     "/defaultNamespace/workflows/0/schedule/cron",
+			code:     IssueInvalidSchedule,
+		},
+		"unknown time zone": {
+			schedule: Schedule{Cron: "@daily", TimeZone: "Mars/Olympus_Mons"},
+			path:     "/defaultNamespace/workflows/0/schedule/timeZone",
+			code:     IssueInvalidSchedule,
+		},
+		"invalid start": {
+			schedule: Schedule{Cron: "@daily", Start: "tomorrow", End: "2023-01-01T00:00:00Z"},
+			path:     "/defaultNamespace/workflows/0/schedule/start",
+			code:     IssueInvalidSchedule,
+		},
+		"end before start": {
+			schedule: Schedule{Cron: "@daily", Start: "2023-01-02T00:00:00Z", End: "2023-01-01T00:00:00Z"},
+			path:     "/defaultNamespace/workflows/0/schedule/end",
+			code:     IssueInvalidSchedule,
+		},
+		"invalid jitter": {
+			schedule: Schedule{Cron: "@daily", Jitter: "a bit"},
+			path:     "/defaultNamespace/workflows/0/schedule/jitter",
+			code:     IssueInvalidDuration,
+		},
+		"unknown overlap policy": {
+			schedule: Schedule{Cron: "@daily", Overlap: "cancel"},
+			path:     "/defaultNamespace/workflows/0/schedule/overlap",
+			code:     IssueUnknownOverlapPolicy,
+		},
+	}
+
+	for name, tc := range tests {
+		schedule := tc.schedule
+
+		conf := dagConfig([]WorkflowStep{{FQMN: "/name/default/fetch"}}, "")
+		conf.DefaultNamespace.Workflows[0].Schedule = &schedule
+
+		issues := conf.Issues()
+		if len(issues) != 1 {
+			t.Errorf("%s: expected a single issue, got %+v", name, issues)
+			continue
+		}
+
+		if issues[0].Path != tc.path || issues[0].Code != tc.code {
+			t.Errorf("%s: expected %s at %s, got %+v", name, tc.code, tc.path, issues[0])
+		}
+	}
+
+	valid := dagConfig([]WorkflowStep{{FQMN: "/name/default/fetch"}}, "")
+	valid.DefaultNamespace.Workflows[0].Schedule = &Schedule{Cron: "0 9 * * MON-FRI", TimeZone: "Europe/Berlin", Jitter: "30s", Overlap: OverlapQueue}
+
+	if err := valid.Validate(); err != nil {
+		t.Error("expected a valid schedule, got:", err)
+	}
+}
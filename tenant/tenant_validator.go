@@ -2,24 +2,54 @@ package tenant
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/suborbital/systemspec/fqmn"
 )
 
-// Validate validates a Config.
+// Validate validates a Config. If any errors are found, a *ValidationError listing every issue
+// (including warnings) is returned. Use Issues to inspect warnings for a valid Config.
 func (c *Config) Validate() (err error) {
+	return c.validate().render()
+}
+
+// Issues returns every issue found while validating the Config, including warnings.
+func (c *Config) Issues() []Issue {
+	return c.validate().issues
+}
+
+func (c *Config) validate() *problems {
 	problems := &problems{}
 
 	c.calculateFQMNs()
 
 	if c.Identifier == "" {
-		problems.add(errors.New("identifier is missing"))
+		problems.add(pointer("identifier"), IssueMissingField, errors.New("identifier is missing"))
 	}
 
 	if len(c.Modules) < 1 {
-		problems.add(errors.New("no modules listed"))
+		problems.add(pointer("modules"), IssueNoModules, errors.New("no modules listed"))
+	}
+
+	namespaces := map[string]bool{fqmn.NamespaceDefault: true}
+
+	for i, ns := range c.Namespaces {
+		switch {
+		case ns.Name == "":
+			problems.add(pointer("namespaces", i, "name"), IssueMissingField, fmt.Errorf("namespace at position %d missing name", i))
+			continue
+		case ns.Name == fqmn.NamespaceDefault:
+			problems.add(pointer("namespaces", i, "name"), IssueReservedNamespace, fmt.Errorf("namespace at position %d uses the reserved name %s, use defaultNamespace instead", i, ns.Name))
+			continue
+		case namespaces[ns.Name]:
+			problems.add(pointer("namespaces", i, "name"), IssueDuplicateNamespace, fmt.Errorf("duplicate namespace %s found", ns.Name))
+			continue
+		}
+
+		namespaces[ns.Name] = true
 	}
 
 	fns := map[string]bool{}
@@ -28,22 +58,25 @@ func (c *Config) Validate() (err error) {
 		namespaced := fmt.Sprintf("%s::%s", f.Namespace, f.Name)
 
 		if _, exists := fns[namespaced]; exists {
-			problems.add(fmt.Errorf("duplicate fn %s found", namespaced))
+			problems.add(pointer("modules", i), IssueDuplicateModule, fmt.Errorf("duplicate fn %s found", namespaced))
 			continue
 		}
 
 		if _, exists := fns[f.Name]; exists {
-			problems.add(fmt.Errorf("duplicate fn %s found", namespaced))
+			problems.add(pointer("modules", i), IssueDuplicateModule, fmt.Errorf("duplicate fn %s found", namespaced))
 			continue
 		}
 
 		if f.Name == "" {
-			problems.add(fmt.Errorf("function at position %d missing name", i))
+			problems.add(pointer("modules", i, "name"), IssueMissingField, fmt.Errorf("function at position %d missing name", i))
 			continue
 		}
 
 		if f.Namespace == "" {
-			problems.add(fmt.Errorf("function at position %d missing namespace", i))
+			problems.add(pointer("modules", i, "namespace"), IssueMissingField, fmt.Errorf("function at position %d missing namespace", i))
+		} else if !namespaces[f.Namespace] {
+			// configs have never been required to declare every module's namespace, so this is only a warning.
+			problems.warn(pointer("modules", i, "namespace"), IssueUndeclaredNamespace, fmt.Errorf("fn %s refers to undeclared namespace %s", namespaced, f.Namespace))
 		}
 
 		// if the fn is in the default namespace, let it exist "naked" and namespaced.
@@ -55,11 +88,17 @@ func (c *Config) Validate() (err error) {
 		}
 	}
 
-	if err := c.validateNamespaceConfig(c.DefaultNamespace); err != nil {
-		problems.add(err)
+	c.validateNamespaceConfig(pointer("defaultNamespace"), fqmn.NamespaceDefault, c.DefaultNamespace, problems)
+
+	for i, ns := range c.Namespaces {
+		if ns.Name == "" || ns.Name == fqmn.NamespaceDefault {
+			continue
+		}
+
+		c.validateNamespaceConfig(pointer("namespaces", i), ns.Name, ns, problems)
 	}
 
-	return problems.render()
+	return problems
 }
 
 type executableType string
@@ -69,28 +108,37 @@ const (
 	executableTypeSchedule = executableType("schedule")
 )
 
-func (c *Config) validateNamespaceConfig(nc NamespaceConfig) (err error) {
-	problems := &problems{}
-
+// validateNamespaceConfig adds the issues found in the namespace at [path] to problems.
+func (c *Config) validateNamespaceConfig(path, namespace string, nc NamespaceConfig, problems *problems) {
 	// validate connections before handlers because we want to make sure they're all correct first.
 	if nc.Connections != nil && len(nc.Connections) > 0 {
-		for _, c := range nc.Connections {
+		connectionNames := map[string]bool{}
+
+		for i, c := range nc.Connections {
 			if c.Type == "" || (c.Type != ConnectionTypeNATS &&
 				c.Type != ConnectionTypeKafka) {
-				problems.add(fmt.Errorf("unknown connection type %s", c.Type))
+				problems.add(path+pointer("connections", i, "type"), IssueUnknownConnectionType, fmt.Errorf("unknown connection type %s", c.Type))
 			}
+
+			if c.Name != "" && connectionNames[c.Name] {
+				problems.add(path+pointer("connections", i, "name"), IssueDuplicateConnection, fmt.Errorf("duplicate connection %s found", c.Name))
+			}
+
+			connectionNames[c.Name] = true
 		}
 	}
 
 	if nc.Authentication != nil {
 		if nc.Authentication.Domains != nil {
 			for d, h := range nc.Authentication.Domains {
+				domainPath := path + pointer("authentication", "domains", d)
+
 				if h.HeaderType == "" {
-					h.HeaderType = "bearer"
+					problems.warn(domainPath+pointer("headerType"), IssueDefaultHeaderType, fmt.Errorf("authentication for domain %s has no header type, bearer will be used", d))
 				}
 
 				if h.Value == "" {
-					problems.add(fmt.Errorf("authentication for domain %s has an empty value", d))
+					problems.add(domainPath+pointer("value"), IssueEmptyAuthValue, fmt.Errorf("authentication for domain %s has an empty value", d))
 				}
 			}
 		}
@@ -99,78 +147,188 @@ func (c *Config) validateNamespaceConfig(nc NamespaceConfig) (err error) {
 	// Conflicting routes will result in a panic which we catch here
 	defer func() {
 		if r := recover(); r != nil {
-			problems.add(fmt.Errorf("%s", r))
+			problems.add(path, IssueInvalidConfig, fmt.Errorf("%s", r))
 		}
-
-		err = problems.render()
 	}()
 
 	uniqueWorkflowNames := map[string]struct{}{}
 
 	for i, w := range nc.Workflows {
+		workflowPath := path + pointer("workflows", i)
+
 		if w.Name == "" {
-			problems.add(fmt.Errorf("workflow at position %d has no name", i))
+			problems.add(workflowPath+pointer("name"), IssueMissingField, fmt.Errorf("workflow at position %d has no name", i))
 			continue
 		}
 
 		if _, exists := uniqueWorkflowNames[w.Name]; exists {
-			problems.add(fmt.Errorf("workflow at position %d has a non-unique name %s", i, w.Name))
+			problems.add(workflowPath+pointer("name"), IssueDuplicateWorkflow, fmt.Errorf("workflow at position %d has a non-unique name %s", i, w.Name))
 		}
 
 		uniqueWorkflowNames[w.Name] = struct{}{}
 
 		if len(w.Steps) == 0 {
-			problems.add(fmt.Errorf("workflow %s missing steps", w.Name))
+			problems.add(workflowPath+pointer("steps"), IssueMissingField, fmt.Errorf("workflow %s missing steps", w.Name))
 			continue
 		}
 
-		c.validateSteps(executableTypeHandler, w.Name, w.Steps, problems)
+		c.validateSteps(executableTypeHandler, workflowPath+pointer("steps"), namespace, w.Name, w.Steps, problems)
 
 		if w.Schedule != nil {
-			if w.Schedule.Every.Seconds == 0 && w.Schedule.Every.Minutes == 0 && w.Schedule.Every.Hours == 0 && w.Schedule.Every.Days == 0 {
-				problems.add(fmt.Errorf("workflow %s's schedule has no 'every' values", w.Name))
-			}
+			validateSchedule(workflowPath+pointer("schedule"), w, problems)
+		}
 
-			// user can provide an 'initial state' via the schedule.State field, so let's prime the state with it.
-			initialState := map[string]bool{}
-			for k := range w.Schedule.State {
-				initialState[k] = true
-			}
+		c.validateState(workflowPath, w, problems)
+
+		if w.IsDAG() {
+			c.validateDAG(workflowPath, w, problems)
+			continue
 		}
 
 		lastStep := w.Steps[len(w.Steps)-1]
 		if w.Response == "" && lastStep.IsGroup() {
-			problems.add(fmt.Errorf("workflow for %s has group as last step but does not include 'response' field", w.Name))
+			problems.add(workflowPath+pointer("response"), IssueMissingResponse, fmt.Errorf("workflow for %s has group as last step but does not include 'response' field", w.Name))
 		}
 	}
-
-	return problems.render()
 }
 
-func (c *Config) validateSteps(exType executableType, name string, steps []WorkflowStep, problems *problems) {
+// validateSteps adds the issues found in the steps at [path] to problems.
+func (c *Config) validateSteps(exType executableType, path, namespace, name string, steps []WorkflowStep, problems *problems) {
 	for j, s := range steps {
+		stepPath := path + pointer(j)
+
 		if !s.IsSingle() && !s.IsGroup() {
-			problems.add(fmt.Errorf("step at position %d for %s %s isn't an Fn or Group", j, exType, name))
+			problems.add(stepPath, IssueInvalidStep, fmt.Errorf("step at position %d for %s %s isn't an Fn or Group", j, exType, name))
 		}
 
 		// this function is key as it compartmentalizes 'step validation', and importantly it
 		// ensures that a Module is available to handle it and binds it by setting the FQMN field.
-		validateFqmn := func(fqmnString string) {
+		validateFqmn := func(fqmnPath, fqmnString string) {
 			module, err := c.FindModule(fqmnString)
 			if err != nil {
-				problems.add(fmt.Errorf("%s for %s lists mod at step %d that does not have a properly formed FQMN: %s", exType, name, j, fqmnString))
+				problems.add(fqmnPath, IssueMalformedFQMN, fmt.Errorf("%s for %s lists mod at step %d that does not have a properly formed FQMN: %s", exType, name, j, fqmnString))
 			} else if module == nil {
-				problems.add(fmt.Errorf("%s for %s lists mod at step %d that does not exist: %s (did you forget a namespace?)", exType, name, j, fqmnString))
+				problems.add(fqmnPath, IssueModuleNotFound, fmt.Errorf("%s for %s lists mod at step %d that does not exist: %s (did you forget a namespace?)", exType, name, j, fqmnString))
+			} else if !moduleVisible(namespace, module.Namespace) {
+				problems.add(fqmnPath, IssueModuleNotVisible, fmt.Errorf("%s for %s lists mod at step %d that is not visible from namespace %s: %s", exType, name, j, namespace, fqmnString))
 			}
 		}
 
 		// the steps below are referenced by index (j) to ensure the addition of the FQMN in validateFn 'sticks'.
 		if s.IsSingle() {
-			validateFqmn(steps[j].FQMN)
+			validateFqmn(stepPath+pointer("fqmn"), steps[j].FQMN)
 		} else if s.IsGroup() {
 			for p := range s.Group {
-				validateFqmn(steps[j].Group[p])
+				validateFqmn(stepPath+pointer("group", p), steps[j].Group[p])
 			}
 		}
+
+		validateErrHandling(exType, stepPath, name, j, steps, problems)
 	}
 }
+
+// validateErrHandling adds the issues found in the retry, timeout, and onErr settings of the step at position [j] to problems.
+func validateErrHandling(exType executableType, stepPath, name string, j int, steps []WorkflowStep, problems *problems) {
+	s := steps[j]
+
+	validateDuration := func(durationPath, field, value string) time.Duration {
+		if value == "" {
+			return 0
+		}
+
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			problems.add(durationPath, IssueInvalidDuration, fmt.Errorf("%s for %s has an invalid %s at step %d: %q is not a positive duration", exType, name, field, j, value))
+		}
+
+		return duration
+	}
+
+	if s.Retries < 0 {
+		problems.add(stepPath+pointer("retries"), IssueInvalidRetries, fmt.Errorf("%s for %s has negative retries at step %d", exType, name, j))
+	}
+
+	validateDuration(stepPath+pointer("timeout"), "timeout", s.Timeout)
+
+	if s.Backoff != nil {
+		backoffPath := stepPath + pointer("backoff")
+
+		initial := validateDuration(backoffPath+pointer("initial"), "initial backoff", s.Backoff.Initial)
+		max := validateDuration(backoffPath+pointer("max"), "max backoff", s.Backoff.Max)
+
+		if s.Backoff.Initial == "" {
+			initial = DefaultBackoffInitial
+		}
+
+		if max > 0 && max < initial {
+			problems.add(backoffPath+pointer("max"), IssueInvalidBackoff, fmt.Errorf("%s for %s has a max backoff shorter than its initial backoff at step %d", exType, name, j))
+		}
+
+		if s.Backoff.Multiplier != 0 && s.Backoff.Multiplier < 1 {
+			problems.add(backoffPath+pointer("multiplier"), IssueInvalidBackoff, fmt.Errorf("%s for %s has a backoff multiplier less than 1 at step %d", exType, name, j))
+		}
+
+		if s.Retries == 0 {
+			problems.warn(backoffPath, IssueUnusedBackoff, fmt.Errorf("%s for %s has a backoff but no retries at step %d", exType, name, j))
+		}
+	}
+
+	if s.OnErr == nil {
+		return
+	}
+
+	onErrPath := stepPath + pointer("onErr")
+
+	switch s.ErrPolicy() {
+	case ErrPolicyReturn, ErrPolicyContinue:
+		if s.OnErr.Step != "" {
+			problems.warn(onErrPath+pointer("step"), IssueInvalidFallback, fmt.Errorf("%s for %s names a fallback step at step %d, but its policy is %s so it will not be used", exType, name, j, s.ErrPolicy()))
+		}
+	case ErrPolicyFallback:
+		validateFallback(exType, onErrPath+pointer("step"), name, j, steps, problems)
+	default:
+		problems.add(onErrPath+pointer("policy"), IssueUnknownErrPolicy, fmt.Errorf("%s for %s has unknown onErr policy %s at step %d", exType, name, s.OnErr.Policy, j))
+	}
+}
+
+// validateFallback adds a problem if the fallback of the step at position [j] can't be run in its place.
+func validateFallback(exType executableType, path, name string, j int, steps []WorkflowStep, problems *problems) {
+	target := steps[j].OnErr.Step
+
+	if target == "" {
+		problems.add(path, IssueMissingField, fmt.Errorf("%s for %s has a fallback onErr policy but no fallback step at step %d", exType, name, j))
+		return
+	}
+
+	fallback := -1
+
+	for i, s := range steps {
+		if s.ID == target {
+			fallback = i
+			break
+		}
+	}
+
+	w := Workflow{Steps: steps}
+
+	switch {
+	case fallback == -1:
+		problems.add(path, IssueInvalidFallback, fmt.Errorf("%s for %s has a fallback at step %d that names unknown step %s", exType, name, j, target))
+	case fallback == j:
+		problems.add(path, IssueInvalidFallback, fmt.Errorf("%s for %s has a step that is its own fallback at step %d", exType, name, j))
+	case w.IsDAG() && stepDependsOn(steps, target, steps[j].ID):
+		problems.add(path, IssueInvalidFallback, fmt.Errorf("%s for %s has a fallback at step %d that depends on the failed step: %s", exType, name, j, target))
+	case !w.IsDAG() && fallback < j:
+		problems.add(path, IssueInvalidFallback, fmt.Errorf("%s for %s has a fallback at step %d that comes before it: %s", exType, name, j, target))
+	}
+}
+
+// moduleVisible returns true if a module in [moduleNamespace] can be used by workflows in [namespace],
+// following the rules documented on NamespaceConfig.
+func moduleVisible(namespace, moduleNamespace string) bool {
+	if namespace == fqmn.NamespaceDefault || moduleNamespace == fqmn.NamespaceDefault || moduleNamespace == namespace {
+		return true
+	}
+
+	return strings.HasPrefix(namespace, moduleNamespace+"/")
+}
@@ -0,0 +1,132 @@
+package tenant
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity indicates whether a validation issue makes a Config invalid.
+type Severity string
+
+// SeverityError and others represent the severities of validation issues.
+const (
+	SeverityError   = Severity("error")
+	SeverityWarning = Severity("warning")
+)
+
+// IssueCode is a stable, machine-readable identifier for a kind of validation issue.
+type IssueCode string
+
+// IssueMissingField and others represent the kinds of validation issue.
+const (
+	IssueMissingField          = IssueCode("missing_field")
+	IssueNoModules             = IssueCode("no_modules")
+	IssueDuplicateModule       = IssueCode("duplicate_module")
+	IssueDuplicateNamespace    = IssueCode("duplicate_namespace")
+	IssueReservedNamespace     = IssueCode("reserved_namespace")
+	IssueUndeclaredNamespace   = IssueCode("undeclared_namespace")
+	IssueUnknownConnectionType = IssueCode("unknown_connection_type")
+	IssueDuplicateConnection   = IssueCode("duplicate_connection")
+	IssueEmptyAuthValue        = IssueCode("empty_auth_value")
+	IssueDefaultHeaderType     = IssueCode("default_header_type")
+	IssueDuplicateWorkflow     = IssueCode("duplicate_workflow")
+	IssueEmptySchedule         = IssueCode("empty_schedule")
+	IssueInvalidSchedule       = IssueCode("invalid_schedule")
+	IssueUnknownOverlapPolicy  = IssueCode("unknown_overlap_policy")
+	IssueMissingResponse       = IssueCode("missing_response")
+	IssueInvalidStep           = IssueCode("invalid_step")
+	IssueMalformedFQMN         = IssueCode("malformed_fqmn")
+	IssueModuleNotFound        = IssueCode("module_not_found")
+	IssueModuleNotVisible      = IssueCode("module_not_visible")
+	IssueDuplicateStepID       = IssueCode("duplicate_step_id")
+	IssueUnknownDependency     = IssueCode("unknown_dependency")
+	IssueDependencyCycle       = IssueCode("dependency_cycle")
+	IssueUnreachableStep       = IssueCode("unreachable_step")
+	IssueInvalidRetries        = IssueCode("invalid_retries")
+	IssueInvalidDuration       = IssueCode("invalid_duration")
+	IssueInvalidBackoff        = IssueCode("invalid_backoff")
+	IssueUnusedBackoff         = IssueCode("unused_backoff")
+	IssueUnknownErrPolicy      = IssueCode("unknown_err_policy")
+	IssueInvalidFallback       = IssueCode("invalid_fallback")
+	IssueUnknownConditionOp    = IssueCode("unknown_condition_op")
+	IssueUnknownStateKey       = IssueCode("unknown_state_key")
+	IssueConditionalStateKey   = IssueCode("conditional_state_key")
+	IssueUnproducedResponse    = IssueCode("unproduced_response")
+	IssueInvalidConfig         = IssueCode("invalid_config")
+)
+
+// Issue is a single problem found while validating a Config.
+type Issue struct {
+	// Path is a JSON pointer to the offending field, such as /namespaces/2/workflows/0/steps/3.
+	Path     string    `json:"path"`
+	Severity Severity  `json:"severity"`
+	Code     IssueCode `json:"code"`
+	Message  string    `json:"message"`
+}
+
+// String returns the issue's path and message.
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// ValidationError is returned by Config.Validate and lists every issue that was found.
+type ValidationError struct {
+	Issues []Issue `json:"issues"`
+}
+
+// Error returns a description of every issue.
+func (v *ValidationError) Error() string {
+	text := fmt.Sprintf("found %d problems:", len(v.Issues))
+
+	for _, issue := range v.Issues {
+		text += fmt.Sprintf("\n\t%s", issue)
+	}
+
+	return text
+}
+
+// HasErrors returns true if any of the issues has SeverityError.
+func (v *ValidationError) HasErrors() bool {
+	for _, issue := range v.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pointer builds a JSON pointer from the given reference tokens, escaping them as described by RFC 6901.
+func pointer(tokens ...any) string {
+	b := strings.Builder{}
+
+	for _, token := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(fmt.Sprint(token)))
+	}
+
+	return b.String()
+}
+
+// problems collects the issues found while validating a Config.
+type problems struct {
+	issues []Issue
+}
+
+func (p *problems) add(path string, code IssueCode, err error) {
+	p.issues = append(p.issues, Issue{Path: path, Severity: SeverityError, Code: code, Message: err.Error()})
+}
+
+func (p *problems) warn(path string, code IssueCode, err error) {
+	p.issues = append(p.issues, Issue{Path: path, Severity: SeverityWarning, Code: code, Message: err.Error()})
+}
+
+// render returns a ValidationError containing every issue if any of them is an error, otherwise nil.
+func (p *problems) render() error {
+	v := &ValidationError{Issues: p.issues}
+	if !v.HasErrors() {
+		return nil
+	}
+
+	return v
+}
@@ -0,0 +1,378 @@
+package tenant
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/suborbital/systemspec/fqmn"
+)
+
+var (
+	ErrMissingStepID      = errors.New("DAG workflow step is missing an id")
+	ErrDuplicateStepID    = errors.New("workflow has a duplicate step id")
+	ErrUnknownDependency  = errors.New("workflow step depends on an unknown step")
+	ErrDependencyCycle    = errors.New("workflow steps have a dependency cycle")
+	ErrNoResponseProducer = errors.New("no workflow step produces the response")
+)
+
+// Stage is a set of workflow steps that can run in parallel once every step in the previous stages has completed.
+type Stage []PlannedStep
+
+// PlannedStep is a workflow step placed in a Stage.
+type PlannedStep struct {
+	WorkflowStep
+
+	// Fallback is true if the step is named as the fallback of another step in a DAG workflow, so it
+	// only runs if a step it stands in for fails, see ErrPolicyFallback.
+	Fallback bool
+}
+
+// IsDAG returns true if any of the workflow's steps lists dependencies, in which case the steps
+// form a directed acyclic graph rather than a sequence.
+func (w *Workflow) IsDAG() bool {
+	for _, s := range w.Steps {
+		if len(s.DependsOn) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Plan returns the order in which the workflow's steps should be executed. For a sequential workflow, each step is its
+// own stage. For a DAG workflow, each step is placed in the earliest stage after all of its dependencies, giving the
+// maximum parallelism. Steps within a stage are in the order they are declared. Fallback steps are planned like any
+// other and marked as such, but only run if needed. As the dependents of a step with a fallback wait for the fallback
+// step if that step fails, they are planned after both.
+func (w *Workflow) Plan() ([]Stage, error) {
+	if !w.IsDAG() {
+		stages := make([]Stage, len(w.Steps))
+		for i, s := range w.Steps {
+			stages[i] = Stage{{WorkflowStep: s}}
+		}
+
+		return stages, nil
+	}
+
+	index, err := stepIndex(w.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// deps holds the positions of the steps that each step must wait for.
+	deps := make([]map[int]bool, len(w.Steps))
+
+	for i, s := range w.Steps {
+		deps[i] = map[int]bool{}
+
+		for _, dep := range s.DependsOn {
+			j, exists := index[dep]
+			if !exists {
+				return nil, errors.Wrapf(ErrUnknownDependency, "step %s depends on %s", s.ID, dep)
+			}
+
+			deps[i][j] = true
+
+			if f, exists := fallbackIndex(w.Steps, j, index); exists && f != i {
+				deps[i][f] = true
+			}
+		}
+	}
+
+	fallbacks := w.fallbackSteps()
+
+	indegree := make([]int, len(w.Steps))
+	dependents := make([][]int, len(w.Steps))
+
+	for i := range w.Steps {
+		// iterate in order so that dependents are planned in the order they are declared.
+		for j := range w.Steps {
+			if deps[i][j] {
+				indegree[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	ready := []int{}
+
+	for i := range w.Steps {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	stages := []Stage{}
+	scheduled := 0
+
+	for len(ready) > 0 {
+		sort.Ints(ready)
+
+		stage := Stage{}
+		next := []int{}
+
+		for _, i := range ready {
+			stage = append(stage, PlannedStep{WorkflowStep: w.Steps[i], Fallback: fallbacks[w.Steps[i].ID]})
+
+			for _, d := range dependents[i] {
+				indegree[d]--
+				if indegree[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+
+		stages = append(stages, stage)
+		scheduled += len(ready)
+		ready = next
+	}
+
+	if scheduled < len(w.Steps) {
+		cyclic := []string{}
+
+		for i, s := range w.Steps {
+			if indegree[i] > 0 {
+				cyclic = append(cyclic, s.ID)
+			}
+		}
+
+		return nil, errors.Wrapf(ErrDependencyCycle, "involving steps %s", strings.Join(cyclic, ", "))
+	}
+
+	return stages, nil
+}
+
+// ResponseStep returns the step of a DAG workflow whose result is the workflow's response. If Response is set, that is the
+// step with that ID, otherwise the single step whose FQMN names that module. If Response is not set, the workflow must
+// have exactly one final step (one that no other step depends on, and that isn't a fallback step), which is returned.
+func (w *Workflow) ResponseStep() (*WorkflowStep, error) {
+	if w.Response != "" {
+		for i, s := range w.Steps {
+			if s.ID == w.Response {
+				return &w.Steps[i], nil
+			}
+		}
+
+		for i, s := range w.Steps {
+			if f, err := fqmn.Parse(s.FQMN); err == nil && s.IsSingle() && f.Name == w.Response {
+				return &w.Steps[i], nil
+			}
+		}
+
+		return nil, errors.Wrapf(ErrNoResponseProducer, "response %s does not name a step or module", w.Response)
+	}
+
+	dependedOn := map[string]bool{}
+
+	for _, s := range w.Steps {
+		for _, dep := range s.DependsOn {
+			dependedOn[dep] = true
+		}
+	}
+
+	fallbacks := w.fallbackSteps()
+	final := []int{}
+
+	for i, s := range w.Steps {
+		// a fallback step stands in for the step that names it, so it is never final in its own right.
+		if !dependedOn[s.ID] && !fallbacks[s.ID] {
+			final = append(final, i)
+		}
+	}
+
+	if len(final) != 1 {
+		return nil, errors.Wrapf(ErrNoResponseProducer, "workflow has %d final steps, so 'response' must name one", len(final))
+	}
+
+	return &w.Steps[final[0]], nil
+}
+
+// fallbackSteps returns the IDs of the steps named as the fallback of another step in a DAG workflow.
+func (w *Workflow) fallbackSteps() map[string]bool {
+	fallbacks := map[string]bool{}
+
+	if !w.IsDAG() {
+		return fallbacks
+	}
+
+	for _, s := range w.Steps {
+		if s.ErrPolicy() == ErrPolicyFallback && s.OnErr.Step != "" {
+			fallbacks[s.OnErr.Step] = true
+		}
+	}
+
+	return fallbacks
+}
+
+// fallbackIndex returns the position of the fallback of the step at position [j], if it has one.
+func fallbackIndex(steps []WorkflowStep, j int, index map[string]int) (int, bool) {
+	if steps[j].ErrPolicy() != ErrPolicyFallback {
+		return 0, false
+	}
+
+	f, exists := index[steps[j].OnErr.Step]
+
+	return f, exists
+}
+
+// stepIndex maps the ID of each step to its position.
+func stepIndex(steps []WorkflowStep) (map[string]int, error) {
+	index := map[string]int{}
+
+	for i, s := range steps {
+		if s.ID == "" {
+			return nil, errors.Wrapf(ErrMissingStepID, "step at position %d", i)
+		}
+
+		if _, exists := index[s.ID]; exists {
+			return nil, errors.Wrap(ErrDuplicateStepID, s.ID)
+		}
+
+		index[s.ID] = i
+	}
+
+	return index, nil
+}
+
+// stepDependsOn returns true if the step with ID [from] depends, directly or transitively, on the step with ID [on].
+func stepDependsOn(steps []WorkflowStep, from, on string) bool {
+	deps := map[string][]string{}
+	for _, s := range steps {
+		deps[s.ID] = s.DependsOn
+	}
+
+	visited := map[string]bool{}
+
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		if visited[id] {
+			return false
+		}
+
+		visited[id] = true
+
+		for _, dep := range deps[id] {
+			if dep == on || visit(dep) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return visit(from)
+}
+
+// stepAncestors returns the positions of the steps that the step at position [j] depends on, directly or transitively.
+func stepAncestors(steps []WorkflowStep, j int) map[int]bool {
+	index := map[string]int{}
+	for i, s := range steps {
+		if _, exists := index[s.ID]; !exists && s.ID != "" {
+			index[s.ID] = i
+		}
+	}
+
+	ancestors := map[int]bool{}
+
+	var visit func(i int)
+	visit = func(i int) {
+		for _, dep := range steps[i].DependsOn {
+			d, exists := index[dep]
+			if !exists || ancestors[d] {
+				continue
+			}
+
+			ancestors[d] = true
+			visit(d)
+		}
+	}
+
+	visit(j)
+
+	return ancestors
+}
+
+// validateDAG adds the issues found in a DAG workflow at [path] to problems.
+func (c *Config) validateDAG(path string, w Workflow, problems *problems) {
+	ids := map[string]bool{}
+	valid := true
+
+	for i, s := range w.Steps {
+		stepPath := path + pointer("steps", i)
+
+		if s.ID == "" {
+			problems.add(stepPath+pointer("id"), IssueMissingField, fmt.Errorf("step at position %d for workflow %s has no id, which is required when steps list dependencies", i, w.Name))
+			valid = false
+
+			continue
+		}
+
+		if ids[s.ID] {
+			problems.add(stepPath+pointer("id"), IssueDuplicateStepID, fmt.Errorf("workflow %s has a duplicate step id %s", w.Name, s.ID))
+			valid = false
+		}
+
+		ids[s.ID] = true
+	}
+
+	for i, s := range w.Steps {
+		for j, dep := range s.DependsOn {
+			if !ids[dep] {
+				problems.add(path+pointer("steps", i, "dependsOn", j), IssueUnknownDependency, fmt.Errorf("step %s for workflow %s depends on unknown step %s", s.ID, w.Name, dep))
+				valid = false
+			}
+		}
+	}
+
+	if !valid {
+		return
+	}
+
+	if _, err := w.Plan(); err != nil {
+		problems.add(path+pointer("steps"), IssueDependencyCycle, fmt.Errorf("workflow %s: %s", w.Name, err.Error()))
+		return
+	}
+
+	response, err := w.ResponseStep()
+	if err != nil {
+		problems.add(path+pointer("response"), IssueMissingResponse, fmt.Errorf("workflow %s: %s", w.Name, err.Error()))
+		return
+	}
+
+	// any step that the response doesn't depend on does work whose result is never used.
+	index, _ := stepIndex(w.Steps)
+	needed := map[string]bool{}
+
+	var visit func(id string)
+	visit = func(id string) {
+		if needed[id] {
+			return
+		}
+
+		needed[id] = true
+
+		step := w.Steps[index[id]]
+
+		for _, dep := range step.DependsOn {
+			visit(dep)
+		}
+
+		// a fallback stands in for the step that names it, so it contributes whenever that step does.
+		if step.ErrPolicy() == ErrPolicyFallback {
+			if _, exists := index[step.OnErr.Step]; exists {
+				visit(step.OnErr.Step)
+			}
+		}
+	}
+
+	visit(response.ID)
+
+	for i, s := range w.Steps {
+		if !needed[s.ID] {
+			problems.warn(path+pointer("steps", i), IssueUnreachableStep, fmt.Errorf("step %s for workflow %s does not contribute to the response %s", s.ID, w.Name, response.ID))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+)
+
+func dagConfig(steps []WorkflowStep, response string) Config {
+	return Config{
+		Identifier:    "dev.suborbital.appname",
+		TenantVersion: 1,
+		Modules: []Module{
+			{Name: "fetch", Namespace: "default"},
+			{Name: "enrich", Namespace: "default"},
+			{Name: "score", Namespace: "default"},
+			{Name: "merge", Namespace: "default"},
+		},
+		DefaultNamespace: NamespaceConfig{
+			Workflows: []Workflow{{Name: "pipeline", Steps: steps, Response: response}},
+		},
+	}
+}
+
+func TestWorkflowPlan(t *testing.T) {
+	conf := dagConfig([]WorkflowStep{
+		{ID: "merge", FQMN: "/name/default/merge", DependsOn: []string{"enrich", "score"}},
+		{ID: "fetch", FQMN: "/name/default/fetch"},
+		{ID: "enrich", FQMN: "/name/default/enrich", DependsOn: []string{"fetch"}},
+		{ID: "score", FQMN: "/name/default/score", DependsOn: []string{"fetch"}},
+	}, "")
+
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := conf.DefaultNamespace.Workflows[0]
+
+	stages, err := w.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{{"fetch"}, {"enrich", "score"}, {"merge"}}
+	if len(stages) != len(expected) {
+		t.Fatalf("expected %d stages, got %+v", len(expected), stages)
+	}
+
+	for i, stage := range stages {
+		if len(stage) != len(expected[i]) {
+			t.Fatalf("unexpected stage %d: %+v", i, stage)
+		}
+
+		for j, step := range stage {
+			if step.ID != expected[i][j] {
+				t.Errorf("expected step %s at stage %d position %d, got %s", expected[i][j], i, j, step.ID)
+			}
+		}
+	}
+
+	response, err := w.ResponseStep()
+	if err != nil || response.ID != "merge" {
+		t.Errorf("expected merge to produce the response, got %+v (%v)", response, err)
+	}
+
+	// dependents of a step with a fallback wait for both, as the fallback stands in for it if it fails.
+	conf = dagConfig([]WorkflowStep{
+		{ID: "x", FQMN: "/name/default/fetch"},
+		{ID: "a", FQMN: "/name/default/enrich", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "f"}},
+		{ID: "f", FQMN: "/name/default/score", DependsOn: []string{"x"}},
+		{ID: "b", FQMN: "/name/default/merge", DependsOn: []string{"a"}},
+	}, "")
+
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	w = conf.DefaultNamespace.Workflows[0]
+
+	stages, err = w.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stages) != 3 || len(stages[1]) != 1 || stages[1][0].ID != "f" || !stages[1][0].Fallback || stages[2][0].ID != "b" || stages[2][0].Fallback {
+		t.Errorf("expected the fallback to be planned before the dependent of the step it stands in for, got %+v", stages)
+	}
+
+	if response, err := w.ResponseStep(); err != nil || response.ID != "b" {
+		t.Errorf("expected b to produce the response, got %+v (%v)", response, err)
+	}
+
+	sequential := Workflow{Steps: []WorkflowStep{{FQMN: "/name/default/fetch"}, {Group: []string{"/name/default/enrich", "/name/default/score"}}}}
+
+	if stages, err := sequential.Plan(); err != nil || len(stages) != 2 || len(stages[1][0].Group) != 2 {
+		t.Errorf("expected each sequential step to be its own stage, got %+v (%v)", stages, err)
+	}
+}
+
+func TestWorkflowDAGValidation(t *testing.T) {
+	cyclic := Workflow{Steps: []WorkflowStep{
+		{ID: "a", FQMN: "/name/default/fetch", DependsOn: []string{"b"}},
+		{ID: "b", FQMN: "/name/default/enrich", DependsOn: []string{"a"}},
+	}}
+
+	if _, err := cyclic.Plan(); !errors.Is(err, ErrDependencyCycle) {
+		t.Error("expected ErrDependencyCycle, got:", err)
+	}
+
+	tests := map[string]struct {
+		steps    []WorkflowStep
+		response string
+		path     string
+		code     IssueCode
+		severity Severity
+	}{
+		"cycle": {
+			steps:    cyclic.Steps,
+			path:     "/defaultNamespace/workflows/0/steps",
+			code:     IssueDependencyCycle,
+			severity: SeverityError,
+		},
+		"unknown dependency": {
+			steps: []WorkflowStep{
+				{ID: "a", FQMN: "/name/default/fetch"},
+				{ID: "b", FQMN: "/name/default/enrich", DependsOn: []string{"a", "missing"}},
+			},
+			path:     "/defaultNamespace/workflows/0/steps/1/dependsOn/1",
+			code:     IssueUnknownDependency,
+			severity: SeverityError,
+		},
+		"missing id": {
+			steps: []WorkflowStep{
+				{ID: "a", FQMN: "/name/default/fetch"},
+				{FQMN: "/name/default/enrich", DependsOn: []string{"a"}},
+			},
+			path:     "/defaultNamespace/workflows/0/steps/1/id",
+			code:     IssueMissingField,
+			severity: SeverityError,
+		},
+		"several final steps": {
+			steps: []WorkflowStep{
+				{ID: "a", FQMN: "/name/default/fetch"},
+				{ID: "b", FQMN: "/name/default/enrich", DependsOn: []string{"a"}},
+				{ID: "c", FQMN: "/name/default/score", DependsOn: []string{"a"}},
+			},
+			path:     "/defaultNamespace/workflows/0/response",
+			code:     IssueMissingResponse,
+			severity: SeverityError,
+		},
+		"unknown response": {
+			steps: []WorkflowStep{
+				{ID: "a", FQMN: "/name/default/fetch"},
+				{ID: "b", FQMN: "/name/default/enrich", DependsOn: []string{"a"}},
+			},
+			response: "missing",
+			path:     "/defaultNamespace/workflows/0/response",
+			code:     IssueMissingResponse,
+			severity: SeverityError,
+		},
+		"unreachable step": {
+			steps: []WorkflowStep{
+				{ID: "a", FQMN: "/name/default/fetch"},
+				{ID: "b", FQMN: "/name/default/enrich", DependsOn: []string{"a"}},
+				{ID: "c", FQMN: "/name/default/score", DependsOn: []string{"a"}},
+			},
+			response: "enrich",
+			path:     "/defaultNamespace/workflows/0/steps/2",
+			code:     IssueUnreachableStep,
+			severity: SeverityWarning,
+		},
+	}
+
+	for name, tc := range tests {
+		conf := dagConfig(tc.steps, tc.response)
+
+		issues := conf.Issues()
+		if len(issues) != 1 {
+			t.Errorf("%s: expected a single issue, got %+v", name, issues)
+			continue
+		}
+
+		if issues[0].Path != tc.path || issues[0].Code != tc.code || issues[0].Severity != tc.severity {
+			t.Errorf("%s: expected %s %s at %s, got %+v", name, tc.severity, tc.code, tc.path, issues[0])
+		}
+	}
+}
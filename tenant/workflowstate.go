@@ -0,0 +1,299 @@
+package tenant
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/suborbital/systemspec/fqmn"
+)
+
+// ConditionOp is the comparison made by a Condition.
+type ConditionOp string
+
+// ConditionExists and others are the comparisons a Condition can make.
+const (
+	ConditionExists    = ConditionOp("exists")
+	ConditionNotExists = ConditionOp("notExists")
+	ConditionEquals    = ConditionOp("equals")
+	ConditionNotEquals = ConditionOp("notEquals")
+)
+
+// Condition is a test of a single key in a workflow's state, used by WorkflowStep.When.
+type Condition struct {
+	Key   string      `yaml:"key" json:"key"`
+	Op    ConditionOp `yaml:"op" json:"op"`
+	Value string      `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// Evaluate returns true if the condition holds for the given state.
+func (c Condition) Evaluate(state map[string][]byte) bool {
+	value, exists := state[c.Key]
+
+	switch c.Op {
+	case ConditionExists:
+		return exists
+	case ConditionNotExists:
+		return !exists
+	case ConditionEquals:
+		return exists && string(value) == c.Value
+	case ConditionNotEquals:
+		return !exists || string(value) != c.Value
+	}
+
+	return false
+}
+
+// ShouldRun returns true if every one of the step's When conditions holds for the given state.
+func (e WorkflowStep) ShouldRun(state map[string][]byte) bool {
+	for _, c := range e.When {
+		if !c.Evaluate(state) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InputState returns the state that the step's modules receive. Without With, that is the whole state,
+// otherwise it is each of the mapped keys that exist in the state, under the names given by With.
+func (e WorkflowStep) InputState(state map[string][]byte) map[string][]byte {
+	if len(e.With) == 0 {
+		return state
+	}
+
+	input := map[string][]byte{}
+
+	for alias, key := range e.With {
+		if value, exists := state[key]; exists {
+			input[alias] = value
+		}
+	}
+
+	return input
+}
+
+// OutputKeys returns the state keys that the step's output is stored under: As if it is set, otherwise
+// the name of each module that the step runs.
+func (e WorkflowStep) OutputKeys() []string {
+	if e.IsSingle() && e.As != "" {
+		return []string{e.As}
+	}
+
+	refs := e.Group
+	if e.IsSingle() {
+		refs = []string{e.FQMN}
+	}
+
+	keys := make([]string, 0, len(refs))
+
+	for _, ref := range refs {
+		if f, err := fqmn.Parse(ref); err == nil {
+			keys = append(keys, f.Name)
+		} else {
+			keys = append(keys, ref)
+		}
+	}
+
+	return keys
+}
+
+// mayBeSkipped returns true if the step could complete without producing its output, because its conditions
+// don't hold or because it failed and its onErr policy lets the workflow carry on.
+func (e WorkflowStep) mayBeSkipped() bool {
+	return len(e.When) > 0 || e.ErrPolicy() != ErrPolicyReturn
+}
+
+// stateKeys maps the keys available in a workflow's state to true if they are certain to be present, or
+// false if the step that produces them might not have.
+type stateKeys map[string]bool
+
+func (s stateKeys) add(key string, certain bool) {
+	s[key] = s[key] || certain
+}
+
+// stepModules returns the modules that the step runs along with the path of each one's reference relative
+// to the step, skipping any that can't be found.
+func (c *Config) stepModules(s WorkflowStep) ([]*Module, []string) {
+	refs, paths := []string{s.FQMN}, []string{pointer("fqmn")}
+
+	if s.IsGroup() {
+		refs, paths = s.Group, []string{}
+		for p := range s.Group {
+			paths = append(paths, pointer("group", p))
+		}
+	}
+
+	modules, modulePaths := []*Module{}, []string{}
+
+	for i, ref := range refs {
+		if module, err := c.FindModule(ref); err == nil && module != nil {
+			modules = append(modules, module)
+			modulePaths = append(modulePaths, paths[i])
+		}
+	}
+
+	return modules, modulePaths
+}
+
+// producedKeys returns the state keys that the step writes: its output keys, and the outputs declared by its modules.
+func (c *Config) producedKeys(s WorkflowStep) []string {
+	keys := s.OutputKeys()
+
+	modules, _ := c.stepModules(s)
+	for _, module := range modules {
+		keys = append(keys, module.Outputs...)
+	}
+
+	return keys
+}
+
+// jumpedSteps returns the positions of the steps in a sequential workflow that don't run if an earlier step
+// fails and the workflow jumps forward to its fallback step.
+func jumpedSteps(w Workflow) map[int]bool {
+	jumped := map[int]bool{}
+
+	if w.IsDAG() {
+		return jumped
+	}
+
+	for i, s := range w.Steps {
+		if s.ErrPolicy() != ErrPolicyFallback {
+			continue
+		}
+
+		for j := i + 1; j < len(w.Steps) && w.Steps[j].ID != s.OnErr.Step; j++ {
+			jumped[j] = true
+		}
+	}
+
+	return jumped
+}
+
+// availableState returns the keys that are available to each of the workflow's steps: those in the
+// schedule's initial state, and those produced by the earlier steps, or for a DAG, by the step's dependencies.
+// It has an extra entry at the end, holding the keys that are available once every step has run.
+func (c *Config) availableState(w Workflow) []stateKeys {
+	available := make([]stateKeys, len(w.Steps)+1)
+	jumped := jumpedSteps(w)
+
+	for j := range available {
+		keys := stateKeys{}
+
+		if w.Schedule != nil {
+			for k := range w.Schedule.State {
+				keys.add(k, true)
+			}
+		}
+
+		producers := []int{}
+
+		if w.IsDAG() && j < len(w.Steps) {
+			for i := range stepAncestors(w.Steps, j) {
+				producers = append(producers, i)
+			}
+		} else {
+			for i := 0; i < j; i++ {
+				producers = append(producers, i)
+			}
+		}
+
+		for _, i := range producers {
+			for _, k := range c.producedKeys(w.Steps[i]) {
+				keys.add(k, !w.Steps[i].mayBeSkipped() && !jumped[i])
+			}
+		}
+
+		available[j] = keys
+	}
+
+	return available
+}
+
+// validateState adds the issues found in the flow of state through the workflow at [path] to problems: keys that
+// are read by a step's conditions, mappings, or modules before any step writes them, and a response that is never written.
+func (c *Config) validateState(path string, w Workflow, problems *problems) {
+	available := c.availableState(w)
+
+	for j, s := range w.Steps {
+		stepPath := path + pointer("steps", j)
+
+		if s.As != "" && !s.IsSingle() {
+			problems.add(stepPath+pointer("as"), IssueInvalidStep, fmt.Errorf("step at position %d for workflow %s uses 'as', which is only allowed for a single module", j, w.Name))
+		}
+
+		for k, cond := range s.When {
+			condPath := stepPath + pointer("when", k)
+
+			switch cond.Op {
+			case ConditionExists, ConditionNotExists, ConditionEquals, ConditionNotEquals:
+			default:
+				problems.add(condPath+pointer("op"), IssueUnknownConditionOp, fmt.Errorf("step at position %d for workflow %s has a condition with unknown op %s", j, w.Name, cond.Op))
+			}
+
+			if cond.Key == "" {
+				problems.add(condPath+pointer("key"), IssueMissingField, fmt.Errorf("step at position %d for workflow %s has a condition with no key", j, w.Name))
+			} else if _, exists := available[j][cond.Key]; !exists {
+				problems.add(condPath+pointer("key"), IssueUnknownStateKey, fmt.Errorf("step at position %d for workflow %s has a condition on state key %s, which no earlier step produces", j, w.Name, cond.Key))
+			}
+		}
+
+		// sort the aliases so that issues are reported in a stable order.
+		aliases := make([]string, 0, len(s.With))
+		for alias := range s.With {
+			aliases = append(aliases, alias)
+		}
+
+		sort.Strings(aliases)
+
+		for _, alias := range aliases {
+			key := s.With[alias]
+			withPath := stepPath + pointer("with", alias)
+
+			certain, exists := available[j][key]
+
+			switch {
+			case key == "":
+				problems.add(withPath, IssueMissingField, fmt.Errorf("step at position %d for workflow %s maps %s to an empty state key", j, w.Name, alias))
+			case !exists:
+				problems.add(withPath, IssueUnknownStateKey, fmt.Errorf("step at position %d for workflow %s maps %s to state key %s, which no earlier step produces", j, w.Name, alias, key))
+			case !certain:
+				problems.warn(withPath, IssueConditionalStateKey, fmt.Errorf("step at position %d for workflow %s maps %s to state key %s, which might not be produced if an earlier step is skipped or fails", j, w.Name, alias, key))
+			}
+		}
+
+		modules, modulePaths := c.stepModules(s)
+
+		for m, module := range modules {
+			modulePath := stepPath + modulePaths[m]
+
+			for _, input := range module.Inputs {
+				// with a mapping, the module sees only the mapped keys, which are checked above.
+				if len(s.With) > 0 {
+					if _, mapped := s.With[input]; !mapped {
+						problems.add(modulePath, IssueUnknownStateKey, fmt.Errorf("step at position %d for workflow %s runs %s, which reads state key %s that 'with' does not map", j, w.Name, module.Name, input))
+					}
+
+					continue
+				}
+
+				certain, exists := available[j][input]
+
+				switch {
+				case !exists:
+					problems.add(modulePath, IssueUnknownStateKey, fmt.Errorf("step at position %d for workflow %s runs %s, which reads state key %s that no earlier step produces", j, w.Name, module.Name, input))
+				case !certain:
+					problems.warn(modulePath, IssueConditionalStateKey, fmt.Errorf("step at position %d for workflow %s runs %s, which reads state key %s that might not be produced if an earlier step is skipped or fails", j, w.Name, module.Name, input))
+				}
+			}
+		}
+	}
+
+	// a DAG's response names a step rather than a state key, and is checked by validateDAG.
+	if w.Response == "" || w.IsDAG() {
+		return
+	}
+
+	if _, exists := available[len(w.Steps)][w.Response]; !exists {
+		problems.warn(path+pointer("response"), IssueUnproducedResponse, fmt.Errorf("workflow %s responds with state key %s, which no step produces", w.Name, w.Response))
+	}
+}
@@ -0,0 +1,226 @@
+package tenant
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkflowStepState(t *testing.T) {
+	step := WorkflowStep{
+		FQMN: "/name/default/enrich",
+		When: []Condition{{Key: "user", Op: ConditionExists}, {Key: "mode", Op: ConditionNotEquals, Value: "dry-run"}},
+		With: map[string]string{"input": "user"},
+		As:   "profile",
+	}
+
+	state := map[string][]byte{"user": []byte("ada"), "mode": []byte("live"), "token": []byte("secret")}
+
+	if !step.ShouldRun(state) {
+		t.Error("expected the step to run")
+	}
+
+	if input := step.InputState(state); !reflect.DeepEqual(input, map[string][]byte{"input": []byte("ada")}) {
+		t.Error("unexpected input state:", input)
+	}
+
+	if keys := step.OutputKeys(); !reflect.DeepEqual(keys, []string{"profile"}) {
+		t.Error("unexpected output keys:", keys)
+	}
+
+	state["mode"] = []byte("dry-run")
+
+	if step.ShouldRun(state) {
+		t.Error("expected the step to be skipped")
+	}
+
+	group := WorkflowStep{Group: []string{"/name/default/fetch", "/name/default/score"}}
+	if keys := group.OutputKeys(); !reflect.DeepEqual(keys, []string{"fetch", "score"}) {
+		t.Error("unexpected group output keys:", keys)
+	}
+}
+
+func TestWorkflowStateValidation(t *testing.T) {
+	valid := dagConfig([]WorkflowStep{
+		{FQMN: "/name/default/fetch", With: map[string]string{"id": "userID"}},
+		{FQMN: "/name/default/enrich", When: []Condition{{Key: "fetch", Op: ConditionExists}}, As: "profile"},
+		{FQMN: "/name/default/merge", When: []Condition{{Key: "profile", Op: ConditionExists}}, With: map[string]string{"user": "fetch"}},
+	}, "")
+
+	valid.DefaultNamespace.Workflows[0].Schedule = &Schedule{Every: ScheduleEvery{Minutes: 5}, State: map[string]string{"userID": "1"}}
+
+	if issues := valid.Issues(); len(issues) != 0 {
+		t.Fatal("expected no issues, got:", issues)
+	}
+
+	tests := map[string]struct {
+		steps    []WorkflowStep
+		path     string
+		code     IssueCode
+		severity Severity
+	}{
+		"unknown with key": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch"},
+				{FQMN: "/name/default/enrich", With: map[string]string{"user": "profile"}},
+			},
+			path: "/defaultNamespace/workflows/0/steps/1/with/user",
+			code: IssueUnknownStateKey,
+		},
+		"key from a later step": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/enrich", When: []Condition{{Key: "fetch", Op: ConditionExists}}},
+				{FQMN: "/name/default/fetch"},
+			},
+			path: "/defaultNamespace/workflows/0/steps/0/when/0/key",
+			code: IssueUnknownStateKey,
+		},
+		"key from a DAG sibling": {
+			steps: []WorkflowStep{
+				{ID: "a", FQMN: "/name/default/fetch"},
+				{ID: "b", FQMN: "/name/default/score", DependsOn: []string{"a"}},
+				{ID: "c", FQMN: "/name/default/enrich", DependsOn: []string{"a"}, With: map[string]string{"s": "score"}},
+				{ID: "d", FQMN: "/name/default/merge", DependsOn: []string{"b", "c"}},
+			},
+			path: "/defaultNamespace/workflows/0/steps/2/with/s",
+			code: IssueUnknownStateKey,
+		},
+		"unknown op": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch"},
+				{FQMN: "/name/default/enrich", When: []Condition{{Key: "fetch", Op: "matches"}}},
+			},
+			path: "/defaultNamespace/workflows/0/steps/1/when/0/op",
+			code: IssueUnknownConditionOp,
+		},
+		"as on a group": {
+			steps: []WorkflowStep{
+				{Group: []string{"/name/default/fetch", "/name/default/score"}, As: "both"},
+				{FQMN: "/name/default/merge"},
+			},
+			path: "/defaultNamespace/workflows/0/steps/0/as",
+			code: IssueInvalidStep,
+		},
+		"output of a skippable step": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyContinue}},
+				{FQMN: "/name/default/enrich", With: map[string]string{"user": "fetch"}},
+			},
+			path:     "/defaultNamespace/workflows/0/steps/1/with/user",
+			code:     IssueConditionalStateKey,
+			severity: SeverityWarning,
+		},
+		"output of a step jumped by a fallback": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "m"}},
+				{FQMN: "/name/default/enrich"},
+				{ID: "m", FQMN: "/name/default/merge", With: map[string]string{"user": "enrich"}},
+			},
+			path:     "/defaultNamespace/workflows/0/steps/2/with/user",
+			code:     IssueConditionalStateKey,
+			severity: SeverityWarning,
+		},
+	}
+
+	for name, tc := range tests {
+		if tc.severity == "" {
+			tc.severity = SeverityError
+		}
+
+		conf := dagConfig(tc.steps, "")
+
+		issues := conf.Issues()
+		if len(issues) != 1 {
+			t.Errorf("%s: expected a single issue, got %+v", name, issues)
+			continue
+		}
+
+		if issues[0].Path != tc.path || issues[0].Code != tc.code || issues[0].Severity != tc.severity {
+			t.Errorf("%s: expected %s %s at %s, got %+v", name, tc.severity, tc.code, tc.path, issues[0])
+		}
+	}
+}
+
+func TestWorkflowDataflowValidation(t *testing.T) {
+	dataflowConfig := func(steps []WorkflowStep, response string) Config {
+		conf := dagConfig(steps, response)
+
+		// fetch writes a token alongside its result, which enrich and score read.
+		conf.Modules[0].Outputs = []string{"token"}
+		conf.Modules[1].Inputs = []string{"token"}
+		conf.Modules[2].Inputs = []string{"token", "fetch"}
+
+		return conf
+	}
+
+	valid := dataflowConfig([]WorkflowStep{
+		{FQMN: "/name/default/fetch"},
+		{Group: []string{"/name/default/enrich", "/name/default/score"}},
+	}, "score")
+
+	if issues := valid.Issues(); len(issues) != 0 {
+		t.Fatal("expected no issues, got:", issues)
+	}
+
+	tests := map[string]struct {
+		steps    []WorkflowStep
+		response string
+		path     string
+		code     IssueCode
+		severity Severity
+	}{
+		"input read before it is written": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/enrich"},
+				{FQMN: "/name/default/fetch"},
+			},
+			path: "/defaultNamespace/workflows/0/steps/0/fqmn",
+			code: IssueUnknownStateKey,
+		},
+		"input hidden by with": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch"},
+				{Group: []string{"/name/default/merge", "/name/default/enrich"}, With: map[string]string{"auth": "token"}},
+			},
+			response: "merge",
+			path:     "/defaultNamespace/workflows/0/steps/1/group/1",
+			code:     IssueUnknownStateKey,
+		},
+		"input from a skippable step": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyContinue}},
+				{FQMN: "/name/default/enrich"},
+			},
+			path:     "/defaultNamespace/workflows/0/steps/1/fqmn",
+			code:     IssueConditionalStateKey,
+			severity: SeverityWarning,
+		},
+		"response never produced": {
+			steps: []WorkflowStep{
+				{FQMN: "/name/default/fetch"},
+				{Group: []string{"/name/default/enrich", "/name/default/merge"}},
+			},
+			response: "score",
+			path:     "/defaultNamespace/workflows/0/response",
+			code:     IssueUnproducedResponse,
+			severity: SeverityWarning,
+		},
+	}
+
+	for name, tc := range tests {
+		if tc.severity == "" {
+			tc.severity = SeverityError
+		}
+
+		conf := dataflowConfig(tc.steps, tc.response)
+
+		issues := conf.Issues()
+		if len(issues) != 1 {
+			t.Errorf("%s: expected a single issue, got %+v", name, issues)
+			continue
+		}
+
+		if issues[0].Path != tc.path || issues[0].Code != tc.code || issues[0].Severity != tc.severity {
+			t.Errorf("%s: expected %s %s at %s, got %+v", name, tc.severity, tc.code, tc.path, issues[0])
+		}
+	}
+}
@@ -2,6 +2,8 @@ package tenant
 
 import (
 	"errors"
+	"math"
+	"time"
 )
 
 var (
@@ -10,10 +12,83 @@ var (
 	ErrSequenceCompleted    = errors.New("sequence is complete, no steps to run")
 )
 
+// ErrPolicy describes what a workflow does when a step fails after exhausting its retries.
+type ErrPolicy string
+
+// ErrPolicyReturn and others are the policies a step's onErr handler can use.
+const (
+	// ErrPolicyReturn stops the workflow and returns the step's error, see ErrSequenceShouldReturn. This is the default.
+	ErrPolicyReturn = ErrPolicy("return")
+	// ErrPolicyContinue records the failure and carries on as though the step had succeeded without producing any output.
+	ErrPolicyContinue = ErrPolicy("continue")
+	// ErrPolicyFallback runs the step named by ErrHandler.Step in place of the failed one. In a sequential
+	// workflow this jumps forward to the fallback step, skipping any steps in between. In a DAG workflow,
+	// a step named as a fallback only runs when a step it stands in for fails, and the steps that depend
+	// on the failed step wait for the fallback step instead.
+	ErrPolicyFallback = ErrPolicy("fallback")
+)
+
+// DefaultBackoffInitial and others are used for retries when a step doesn't specify its own backoff.
+const (
+	DefaultBackoffInitial    = 100 * time.Millisecond
+	DefaultBackoffMultiplier = 2.0
+)
+
 // WorkflowStep represents a step in a workflow.
 type WorkflowStep struct {
 	FQMN  string   `yaml:"fqmn" json:"fqmn"`
 	Group []string `yaml:"group,omitempty" json:"group,omitempty"`
+
+	// ID identifies the step within its workflow, and is required for steps in a DAG workflow.
+	ID string `yaml:"id,omitempty" json:"id,omitempty"`
+
+	// DependsOn lists the IDs of the steps that must complete before this one runs. If any step in a
+	// workflow lists dependencies, the workflow is a DAG rather than a sequence, see Workflow.IsDAG.
+	DependsOn []string `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+
+	// Retries is the number of times a failed module call is retried before OnErr applies. For a group,
+	// each module is retried independently.
+	Retries int `yaml:"retries,omitempty" json:"retries,omitempty"`
+
+	// Backoff controls the delay between retries.
+	Backoff *Backoff `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+
+	// Timeout limits each attempt of a module call, as a Go duration such as 500ms or 2s. A timed out attempt counts as a failure.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// OnErr controls what happens once every attempt has failed.
+	OnErr *ErrHandler `yaml:"onErr,omitempty" json:"onErr,omitempty"`
+
+	// When lists conditions on the workflow's state that must all hold for the step to run. A step whose
+	// conditions don't hold is skipped, and produces no output.
+	When []Condition `yaml:"when,omitempty" json:"when,omitempty"`
+
+	// With maps the state keys that the step's modules see to keys in the workflow's state. If it is
+	// set, the modules receive only the mapped keys, see InputState.
+	With map[string]string `yaml:"with,omitempty" json:"with,omitempty"`
+
+	// As is the state key that a single step's output is stored under, instead of the module's name.
+	As string `yaml:"as,omitempty" json:"as,omitempty"`
+}
+
+// Backoff describes an exponential delay between retries. Durations are Go durations such as 250ms.
+type Backoff struct {
+	// Initial is the delay before the first retry, defaulting to DefaultBackoffInitial.
+	Initial string `yaml:"initial,omitempty" json:"initial,omitempty"`
+
+	// Max caps the delay between retries. There is no cap if it is empty.
+	Max string `yaml:"max,omitempty" json:"max,omitempty"`
+
+	// Multiplier is applied to the delay after each retry, defaulting to DefaultBackoffMultiplier. Use 1 for a constant delay.
+	Multiplier float64 `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`
+}
+
+// ErrHandler describes how a step's failure is handled.
+type ErrHandler struct {
+	Policy ErrPolicy `yaml:"policy" json:"policy"`
+
+	// Step is the ID of the fallback step, and is only used with ErrPolicyFallback.
+	Step string `yaml:"step,omitempty" json:"step,omitempty"`
 }
 
 // IsGroup returns true if the WorkflowStep is a group.
@@ -25,3 +100,61 @@ func (e WorkflowStep) IsGroup() bool {
 func (e WorkflowStep) IsSingle() bool {
 	return e.FQMN != "" && e.Group == nil
 }
+
+// Attempts returns the maximum number of times each of the step's module calls is made.
+func (e WorkflowStep) Attempts() int {
+	if e.Retries < 0 {
+		return 1
+	}
+
+	return e.Retries + 1
+}
+
+// TimeoutDuration returns the time limit for each attempt, or 0 if there is none. The step must have been validated.
+func (e WorkflowStep) TimeoutDuration() time.Duration {
+	timeout, _ := time.ParseDuration(e.Timeout)
+
+	return timeout
+}
+
+// RetryDelay returns how long to wait before the given retry, starting at 1. The step must have been validated.
+func (e WorkflowStep) RetryDelay(retry int) time.Duration {
+	initial, multiplier, max := DefaultBackoffInitial, DefaultBackoffMultiplier, time.Duration(0)
+
+	if e.Backoff != nil {
+		if e.Backoff.Initial != "" {
+			initial, _ = time.ParseDuration(e.Backoff.Initial)
+		}
+
+		if e.Backoff.Multiplier != 0 {
+			multiplier = e.Backoff.Multiplier
+		}
+
+		max, _ = time.ParseDuration(e.Backoff.Max)
+	}
+
+	if retry < 1 {
+		retry = 1
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(retry-1))
+
+	if max > 0 && delay > float64(max) {
+		return max
+	}
+
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(delay)
+}
+
+// ErrPolicy returns the step's error policy, which is ErrPolicyReturn unless OnErr says otherwise.
+func (e WorkflowStep) ErrPolicy() ErrPolicy {
+	if e.OnErr == nil || e.OnErr.Policy == "" {
+		return ErrPolicyReturn
+	}
+
+	return e.OnErr.Policy
+}
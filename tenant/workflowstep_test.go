@@ -0,0 +1,146 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkflowStepRetryDelay(t *testing.T) {
+	step := WorkflowStep{Retries: 4, Backoff: &Backoff{Initial: "100ms", Max: "300ms"}}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+
+	for i, delay := range expected {
+		if actual := step.RetryDelay(i + 1); actual != delay {
+			t.Errorf("expected retry %d to wait %s, got %s", i+1, delay, actual)
+		}
+	}
+
+	constant := WorkflowStep{Retries: 2, Backoff: &Backoff{Initial: "1s", Multiplier: 1}}
+	if constant.RetryDelay(2) != time.Second {
+		t.Error("expected a constant delay of 1s, got", constant.RetryDelay(2))
+	}
+
+	if (WorkflowStep{}).RetryDelay(1) != DefaultBackoffInitial {
+		t.Error("expected the default backoff, got", (WorkflowStep{}).RetryDelay(1))
+	}
+
+	if step.Attempts() != 5 || (WorkflowStep{}).Attempts() != 1 {
+		t.Error("unexpected number of attempts")
+	}
+
+	if (WorkflowStep{Timeout: "2s"}).TimeoutDuration() != 2*time.Second || (WorkflowStep{}).TimeoutDuration() != 0 {
+		t.Error("unexpected timeout")
+	}
+
+	if (WorkflowStep{}).ErrPolicy() != ErrPolicyReturn {
+		t.Error("expected the default policy to be return")
+	}
+}
+
+func TestWorkflowStepErrHandlingValidation(t *testing.T) {
+	valid := dagConfig([]WorkflowStep{
+		{FQMN: "/name/default/fetch", Retries: 3, Backoff: &Backoff{Initial: "50ms", Max: "1s"}, Timeout: "2s", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "cached"}},
+		{FQMN: "/name/default/enrich", OnErr: &ErrHandler{Policy: ErrPolicyContinue}},
+		{ID: "cached", FQMN: "/name/default/merge"},
+	}, "")
+
+	if issues := valid.Issues(); len(issues) != 0 {
+		t.Fatal("expected no issues, got:", issues)
+	}
+
+	tests := map[string]struct {
+		steps    []WorkflowStep
+		path     string
+		code     IssueCode
+		severity Severity
+	}{
+		"negative retries": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", Retries: -1}},
+			path:  "/defaultNamespace/workflows/0/steps/0/retries",
+			code:  IssueInvalidRetries,
+		},
+		"invalid timeout": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", Timeout: "soon"}},
+			path:  "/defaultNamespace/workflows/0/steps/0/timeout",
+			code:  IssueInvalidDuration,
+		},
+		"max below initial": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", Retries: 1, Backoff: &Backoff{Initial: "1s", Max: "10ms"}}},
+			path:  "/defaultNamespace/workflows/0/steps/0/backoff/max",
+			code:  IssueInvalidBackoff,
+		},
+		"shrinking backoff": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", Retries: 1, Backoff: &Backoff{Multiplier: 0.5}}},
+			path:  "/defaultNamespace/workflows/0/steps/0/backoff/multiplier",
+			code:  IssueInvalidBackoff,
+		},
+		"backoff without retries": {
+			steps:    []WorkflowStep{{FQMN: "/name/default/fetch", Backoff: &Backoff{Initial: "1s"}}},
+			path:     "/defaultNamespace/workflows/0/steps/0/backoff",
+			code:     IssueUnusedBackoff,
+			severity: SeverityWarning,
+		},
+		"unknown policy": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: "ignore"}}},
+			path:  "/defaultNamespace/workflows/0/steps/0/onErr/policy",
+			code:  IssueUnknownErrPolicy,
+		},
+		"missing fallback": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyFallback}}},
+			path:  "/defaultNamespace/workflows/0/steps/0/onErr/step",
+			code:  IssueMissingField,
+		},
+		"unknown fallback": {
+			steps: []WorkflowStep{{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "cached"}}},
+			path:  "/defaultNamespace/workflows/0/steps/0/onErr/step",
+			code:  IssueInvalidFallback,
+		},
+		"backwards fallback": {
+			steps: []WorkflowStep{
+				{ID: "cached", FQMN: "/name/default/merge"},
+				{FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "cached"}},
+			},
+			path: "/defaultNamespace/workflows/0/steps/1/onErr/step",
+			code: IssueInvalidFallback,
+		},
+		"fallback depends on failed step": {
+			steps: []WorkflowStep{
+				{ID: "fetch", FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "cached"}},
+				{ID: "cached", FQMN: "/name/default/score", DependsOn: []string{"fetch"}},
+				{ID: "merge", FQMN: "/name/default/merge", DependsOn: []string{"cached"}},
+			},
+			path: "/defaultNamespace/workflows/0/steps/0/onErr/step",
+			code: IssueInvalidFallback,
+		},
+	}
+
+	for name, tc := range tests {
+		if tc.severity == "" {
+			tc.severity = SeverityError
+		}
+
+		conf := dagConfig(tc.steps, "")
+
+		issues := conf.Issues()
+		if len(issues) != 1 {
+			t.Errorf("%s: expected a single issue, got %+v", name, issues)
+			continue
+		}
+
+		if issues[0].Path != tc.path || issues[0].Code != tc.code || issues[0].Severity != tc.severity {
+			t.Errorf("%s: expected %s %s at %s, got %+v", name, tc.severity, tc.code, tc.path, issues[0])
+		}
+	}
+
+	// in a DAG, a fallback contributes to the response whenever the step that names it does.
+	dag := dagConfig([]WorkflowStep{
+		{ID: "fetch", FQMN: "/name/default/fetch", OnErr: &ErrHandler{Policy: ErrPolicyFallback, Step: "cached"}},
+		{ID: "cached", FQMN: "/name/default/score"},
+		{ID: "merge", FQMN: "/name/default/merge", DependsOn: []string{"fetch"}},
+	}, "")
+
+	if issues := dag.Issues(); len(issues) != 0 {
+		t.Error("expected no issues for a DAG fallback, got:", issues)
+	}
+}